@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kubeflowv1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1"
+)
+
+// Condition types reported in the status of a Profile,
+// one for each subsystem reconciled by the controller.
+const (
+	ProfileConditionPodDefaults     = "PodDefaults"
+	ProfileConditionImagePullSecret = "ImagePullSecret"
+	ProfileConditionIntegrations    = "Integrations"
+	ProfileConditionEnvoyFilter     = "EnvoyFilter"
+	ProfileConditionVault           = "Vault" // the secret store, whichever its backend
+	ProfileConditionMinIO           = "MinIO"
+)
+
+// minioInstanceConditionType is the type of the condition of a MinIO
+// instance, such as MinIO/minio_standard.
+func minioInstanceConditionType(instance string) string {
+	return ProfileConditionMinIO + "/" + instance
+}
+
+const (
+	// ReasonSyncFailed is used as the condition reason when a
+	// subsystem of a Profile fails to sync
+	ReasonSyncFailed = "SyncFailed"
+)
+
+// newProfileCondition builds the condition for the subsystem from
+// the outcome of its reconciliation.
+func newProfileCondition(conditionType string, err error) kubeflowv1.ProfileCondition {
+	if err != nil {
+		return kubeflowv1.ProfileCondition{
+			Type:    conditionType,
+			Status:  string(v1.ConditionFalse),
+			Reason:  ReasonSyncFailed,
+			Message: err.Error(),
+		}
+	}
+
+	return kubeflowv1.ProfileCondition{
+		Type:    conditionType,
+		Status:  string(v1.ConditionTrue),
+		Reason:  SuccessSynced,
+		Message: fmt.Sprintf("%s synced successfully", conditionType),
+	}
+}
+
+// setProfileCondition adds or replaces the condition of the same type.
+// The transition time is only moved forward when the status changes.
+func setProfileCondition(conditions []kubeflowv1.ProfileCondition, condition kubeflowv1.ProfileCondition) []kubeflowv1.ProfileCondition {
+	for i, existing := range conditions {
+		if existing.Type != condition.Type {
+			continue
+		}
+
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		} else {
+			condition.LastTransitionTime = metav1.Now()
+		}
+
+		conditions[i] = condition
+		return conditions
+	}
+
+	condition.LastTransitionTime = metav1.Now()
+	return append(conditions, condition)
+}
+
+// staleInstanceConditionTypes returns the types of the conditions of
+// the MinIO instances which aren't in the results, such as removed instances.
+func staleInstanceConditionTypes(conditions []kubeflowv1.ProfileCondition, results MinIOResults) []string {
+	stale := make([]string, 0)
+	for _, condition := range conditions {
+		if !strings.HasPrefix(condition.Type, minioInstanceConditionType("")) {
+			continue
+		}
+
+		if _, ok := results[strings.TrimPrefix(condition.Type, minioInstanceConditionType(""))]; !ok {
+			stale = append(stale, condition.Type)
+		}
+	}
+
+	return stale
+}
+
+// removeProfileConditions removes the conditions of the given types.
+func removeProfileConditions(conditions []kubeflowv1.ProfileCondition, conditionTypes []string) []kubeflowv1.ProfileCondition {
+	result := make([]kubeflowv1.ProfileCondition, 0, len(conditions))
+	for _, condition := range conditions {
+		if !StringArrayContains(conditionTypes, condition.Type) {
+			result = append(result, condition)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stesting "k8s.io/client-go/testing"
+
+	kubeflowv1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1"
+	"github.com/StatCan/kubeflow-controller/pkg/generated/clientset/versioned/fake"
+)
+
+func TestNewProfileCondition(t *testing.T) {
+	condition := newProfileCondition(ProfileConditionVault, nil)
+	if condition.Type != ProfileConditionVault || condition.Status != "True" || condition.Reason != SuccessSynced {
+		t.Errorf("Unexpected condition %+v", condition)
+	}
+
+	condition = newProfileCondition(ProfileConditionVault, errors.New("vault sealed"))
+	if condition.Status != "False" || condition.Reason != ReasonSyncFailed || condition.Message != "vault sealed" {
+		t.Errorf("Unexpected condition %+v", condition)
+	}
+}
+
+// Tests that the transition time of a condition only
+// moves forward when its status changes
+func TestSetProfileCondition(t *testing.T) {
+	earlier := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	synced := kubeflowv1.ProfileCondition{Type: ProfileConditionVault, Status: "True", Reason: SuccessSynced, LastTransitionTime: earlier}
+	other := kubeflowv1.ProfileCondition{Type: ProfileConditionMinIO, Status: "True", Reason: SuccessSynced, LastTransitionTime: earlier}
+
+	tests := map[string]struct {
+		conditions []kubeflowv1.ProfileCondition
+		condition  kubeflowv1.ProfileCondition
+		length     int
+		moved      bool
+	}{
+		"added": {
+			conditions: []kubeflowv1.ProfileCondition{other},
+			condition:  newProfileCondition(ProfileConditionVault, nil),
+			length:     2,
+			moved:      true,
+		},
+		"unchanged": {
+			conditions: []kubeflowv1.ProfileCondition{other, synced},
+			condition:  newProfileCondition(ProfileConditionVault, nil),
+			length:     2,
+		},
+		"new message": {
+			conditions: []kubeflowv1.ProfileCondition{synced},
+			condition:  kubeflowv1.ProfileCondition{Type: ProfileConditionVault, Status: "True", Reason: SuccessSynced, Message: "again"},
+			length:     1,
+		},
+		"failed": {
+			conditions: []kubeflowv1.ProfileCondition{other, synced},
+			condition:  newProfileCondition(ProfileConditionVault, errors.New("vault sealed")),
+			length:     2,
+			moved:      true,
+		},
+	}
+
+	for name, test := range tests {
+		conditions := setProfileCondition(test.conditions, test.condition)
+		if len(conditions) != test.length {
+			t.Errorf("%s: expected %d conditions, got %v", name, test.length, conditions)
+			continue
+		}
+
+		var condition *kubeflowv1.ProfileCondition
+		for i := range conditions {
+			if conditions[i].Type == ProfileConditionVault {
+				condition = &conditions[i]
+			}
+		}
+
+		if condition == nil {
+			t.Errorf("%s: expected the %s condition, got %v", name, ProfileConditionVault, conditions)
+			continue
+		}
+
+		if condition.Status != test.condition.Status || condition.Reason != test.condition.Reason || condition.Message != test.condition.Message {
+			t.Errorf("%s: expected %+v, got %+v", name, test.condition, *condition)
+		}
+
+		if moved := !condition.LastTransitionTime.Equal(&earlier); moved != test.moved {
+			t.Errorf("%s: expected the transition time to move=%v, got %v", name, test.moved, condition.LastTransitionTime)
+		}
+
+		if condition.LastTransitionTime.IsZero() {
+			t.Errorf("%s: expected a transition time", name)
+		}
+	}
+}
+
+func TestRemoveProfileConditions(t *testing.T) {
+	conditions := []kubeflowv1.ProfileCondition{
+		{Type: ProfileConditionVault},
+		{Type: minioInstanceConditionType("minio_standard")},
+		{Type: minioInstanceConditionType("minio_removed")},
+	}
+
+	stale := staleInstanceConditionTypes(conditions, MinIOResults{"minio_standard": nil})
+	if len(stale) != 1 || stale[0] != "MinIO/minio_removed" {
+		t.Fatalf("Expected the condition of the removed instance to be stale, got %v", stale)
+	}
+
+	conditions = removeProfileConditions(conditions, stale)
+	if len(conditions) != 2 || conditions[1].Type != "MinIO/minio_standard" {
+		t.Errorf("Expected the stale condition to be removed, got %v", conditions)
+	}
+}
+
+// Tests that the conditions are written through the status subresource,
+// and that a second sync with the same outcome doesn't write them again
+func TestUpdateProfileStatus(t *testing.T) {
+	profile := newTestProfile()
+	kubeflowclient := fake.NewSimpleClientset(profile)
+	c := &Controller{kubeflowclientset: kubeflowclient}
+
+	conditions := []kubeflowv1.ProfileCondition{
+		newProfileCondition(ProfileConditionVault, nil),
+		newProfileCondition(ProfileConditionMinIO, errors.New("unreachable")),
+	}
+
+	if err := c.updateProfileStatus(profile, conditions, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var updated *kubeflowv1.Profile
+	for _, action := range kubeflowclient.Actions() {
+		if action.GetVerb() == "update" && action.GetSubresource() == "status" {
+			updated = action.(k8stesting.UpdateAction).GetObject().(*kubeflowv1.Profile)
+		}
+	}
+
+	if updated == nil || len(updated.Status.Conditions) != 2 {
+		t.Fatalf("Expected the conditions to be written, got %v", kubeflowclient.Actions())
+	}
+
+	for _, condition := range updated.Status.Conditions {
+		if condition.Reason == "" || condition.LastTransitionTime.IsZero() {
+			t.Errorf("Expected a reason and a transition time, got %+v", condition)
+		}
+	}
+
+	transitionTime := updated.Status.Conditions[0].LastTransitionTime
+	kubeflowclient.ClearActions()
+
+	conditions = []kubeflowv1.ProfileCondition{
+		newProfileCondition(ProfileConditionVault, nil),
+		newProfileCondition(ProfileConditionMinIO, errors.New("unreachable")),
+	}
+	if err := c.updateProfileStatus(updated, conditions, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if actions := kubeflowclient.Actions(); len(actions) != 0 {
+		t.Errorf("Expected the unchanged conditions not to be written, got %v", actions)
+	}
+
+	if !updated.Status.Conditions[0].LastTransitionTime.Equal(&transitionTime) {
+		t.Errorf("Expected the transition time to be kept, got %v", updated.Status.Conditions[0].LastTransitionTime)
+	}
+}
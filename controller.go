@@ -20,6 +20,9 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"k8s.io/apimachinery/pkg/labels"
@@ -27,7 +30,9 @@ import (
 	v1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	v1informers "k8s.io/client-go/informers/core/v1"
@@ -57,7 +62,6 @@ import (
 )
 
 const controllerAgentName = "kubeflow-controller"
-const pachydermNamespace = "pachyderm"
 
 const (
 	// SuccessSynced is used as part of the Event 'reason' when a Profile is synced
@@ -72,6 +76,20 @@ const (
 	// MessageResourceSynced is the message used for an Event fired when a Profile
 	// is synced successfully
 	MessageResourceSynced = "Profile synced successfully"
+
+	// ResourceDrifted is used as part of the Event 'reason' when a resource
+	// managed by a Profile no longer matches its desired state.
+	ResourceDrifted = "ResourceDrifted"
+	// MessageResourceDrifted is the message used for Events when a resource
+	// managed by a Profile is brought back to its desired state
+	MessageResourceDrifted = "%s %q drifted from its desired %s, reconciling it"
+
+	// ErrInvalidVaultPolicy is used as part of the Event 'reason' when the
+	// Vault policy rendered for a Profile is rejected.
+	ErrInvalidVaultPolicy = "ErrInvalidVaultPolicy"
+	// MessageInvalidVaultPolicy is the message used for Events when the
+	// Vault policy rendered for a Profile is rejected
+	MessageInvalidVaultPolicy = "Vault policy was not written: %v"
 )
 
 // Controller is the controller implementation for Profile resources
@@ -96,11 +114,35 @@ type Controller struct {
 	envoyFiltersLister   istionetworkingv1alpha3listers.EnvoyFilterLister
 	envoyFiltersSynced   cache.InformerSynced
 
-	dockerConfigJSON []byte
+	profileIntegrationsLister v1alpha1listers.ProfileIntegrationLister
+	profileIntegrationsSynced cache.InformerSynced
+	configMapsLister          v1listers.ConfigMapLister
+	configMapsSynced          cache.InformerSynced
+
+	// registryCredentials are copied into the namespaces of the Profiles
+	// which select them and attached to imagePullSecretServiceAccounts.
+	registryCredentials            []RegistryCredential
+	defaultRegistryCredentials     []string
+	imagePullSecretServiceAccounts []string
+
+	secretStore SecretStoreProvisioner
+
+	objectStorage ObjectStorageProvisioner
+	// minioBuckets is the default configuration of the buckets of
+	// the Profiles, overridden by their annotations.
+	minioBuckets MinIOBucketConfig
+
+	// purgeDeletedProfiles removes the data of deleted Profiles
+	// instead of archiving it.
+	purgeDeletedProfiles bool
 
-	vaultConfigurer VaultConfigurer
+	// podDefaultsGCDryRun only logs the PodDefaults which are no longer
+	// registered, or whose template was removed, instead of deleting them.
+	podDefaultsGCDryRun bool
 
-	minio MinIO
+	// vaultEntitySweep configures the removal of the Vault
+	// entities which no longer belong to a Profile.
+	vaultEntitySweep VaultEntitySweepConfig
 
 	// workqueue is a rate limited work queue. This is used to queue work to be
 	// processed instead of performing it as soon as a change happens. This
@@ -111,6 +153,13 @@ type Controller struct {
 	// recorder is an event recorder for recording Event resources to the
 	// Kubernetes API.
 	recorder record.EventRecorder
+
+	// workersStarted is set once the workers have been launched and
+	// workersRunning counts the workers currently in their loop.
+	workersStarted int32
+	workersRunning int32
+	// processing tracks when each in-flight work item started processing.
+	processing sync.Map
 }
 
 // NewController returns a new kubeflow controller
@@ -124,9 +173,17 @@ func NewController(
 	roleBindingInformer rbacv1informers.RoleBindingInformer,
 	profileInformer informers.ProfileInformer,
 	envoyFiltersInformer istionetworkingv1alpha3informers.EnvoyFilterInformer,
-	dockerConfigJSON []byte,
-	vaultConfigurer VaultConfigurer,
-	minio MinIO) *Controller {
+	profileIntegrationInformer v1alpha1informers.ProfileIntegrationInformer,
+	podDefaultTemplatesInformer v1informers.ConfigMapInformer,
+	registryCredentials []RegistryCredential,
+	defaultRegistryCredentials []string,
+	imagePullSecretServiceAccounts []string,
+	secretStore SecretStoreProvisioner,
+	objectStorage ObjectStorageProvisioner,
+	minioBuckets MinIOBucketConfig,
+	purgeDeletedProfiles bool,
+	podDefaultsGCDryRun bool,
+	vaultEntitySweep VaultEntitySweepConfig) *Controller {
 
 	// Create event broadcaster
 	// Add kubeflow-controller types to the default Kubernetes Scheme so Events can be
@@ -139,26 +196,36 @@ func NewController(
 	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: controllerAgentName})
 
 	controller := &Controller{
-		kubeclientset:        kubeclientset,
-		kubeflowclientset:    kubeflowclientset,
-		istioclientset:       istioclientset,
-		podDefaultsLister:    podDefaultInformer.Lister(),
-		podDefaultsSynced:    podDefaultInformer.Informer().HasSynced,
-		secretsLister:        secretInformer.Lister(),
-		secretsSynced:        secretInformer.Informer().HasSynced,
-		serviceAccountLister: serviceAccountInformer.Lister(),
-		serviceAccountSynced: serviceAccountInformer.Informer().HasSynced,
-		roleBindingLister:    roleBindingInformer.Lister(),
-		roleBindingSynced:    roleBindingInformer.Informer().HasSynced,
-		profilesLister:       profileInformer.Lister(),
-		profilesSynced:       profileInformer.Informer().HasSynced,
-		envoyFiltersLister:   envoyFiltersInformer.Lister(),
-		envoyFiltersSynced:   envoyFiltersInformer.Informer().HasSynced,
-		dockerConfigJSON:     dockerConfigJSON,
-		vaultConfigurer:      vaultConfigurer,
-		minio:                minio,
-		workqueue:            workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Profiles"),
-		recorder:             recorder,
+		kubeclientset:                  kubeclientset,
+		kubeflowclientset:              kubeflowclientset,
+		istioclientset:                 istioclientset,
+		podDefaultsLister:              podDefaultInformer.Lister(),
+		podDefaultsSynced:              podDefaultInformer.Informer().HasSynced,
+		secretsLister:                  secretInformer.Lister(),
+		secretsSynced:                  secretInformer.Informer().HasSynced,
+		serviceAccountLister:           serviceAccountInformer.Lister(),
+		serviceAccountSynced:           serviceAccountInformer.Informer().HasSynced,
+		roleBindingLister:              roleBindingInformer.Lister(),
+		roleBindingSynced:              roleBindingInformer.Informer().HasSynced,
+		profilesLister:                 profileInformer.Lister(),
+		profilesSynced:                 profileInformer.Informer().HasSynced,
+		envoyFiltersLister:             envoyFiltersInformer.Lister(),
+		envoyFiltersSynced:             envoyFiltersInformer.Informer().HasSynced,
+		profileIntegrationsLister:      profileIntegrationInformer.Lister(),
+		profileIntegrationsSynced:      profileIntegrationInformer.Informer().HasSynced,
+		configMapsLister:               podDefaultTemplatesInformer.Lister(),
+		configMapsSynced:               podDefaultTemplatesInformer.Informer().HasSynced,
+		registryCredentials:            registryCredentials,
+		defaultRegistryCredentials:     defaultRegistryCredentials,
+		imagePullSecretServiceAccounts: imagePullSecretServiceAccounts,
+		secretStore:                    secretStore,
+		objectStorage:                  objectStorage,
+		minioBuckets:                   minioBuckets,
+		purgeDeletedProfiles:           purgeDeletedProfiles,
+		podDefaultsGCDryRun:            podDefaultsGCDryRun,
+		vaultEntitySweep:               vaultEntitySweep,
+		workqueue:                      workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Profiles"),
+		recorder:                       recorder,
 	}
 
 	klog.Info("Setting up event handlers")
@@ -197,8 +264,9 @@ func NewController(
 	// processing. This way, we don't need to implement custom logic for
 	// handling Secret resources. More info on this pattern:
 	// https://github.com/kubernetes/community/blob/8cafef897a22026d42f5e5bb3f104febe7e29830/contributors/devel/controllers.md
+	// Changes to the source of the image pull secrets enqueue every Profile.
 	secretInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: controller.handleObject,
+		AddFunc: controller.handleSecret,
 		UpdateFunc: func(old, new interface{}) {
 			newPD := new.(*v1.Secret)
 			oldPD := old.(*v1.Secret)
@@ -207,9 +275,9 @@ func NewController(
 				// Two different versions of the same Deployment will always have different RVs.
 				return
 			}
-			controller.handleObject(new)
+			controller.handleSecret(new)
 		},
-		DeleteFunc: controller.handleObject,
+		DeleteFunc: controller.handleSecret,
 	})
 
 	// Set up an event handler for when ServiceAccount resources change. This
@@ -275,6 +343,42 @@ func NewController(
 		DeleteFunc: controller.handleObject,
 	})
 
+	// Set up an event handler for when ProfileIntegration resources change.
+	// A ProfileIntegration applies to every Profile, so all of them are
+	// enqueued for processing.
+	profileIntegrationInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.enqueueAllProfiles,
+		UpdateFunc: func(old, new interface{}) {
+			newPI := new.(*kubeflowv1alpha1.ProfileIntegration)
+			oldPI := old.(*kubeflowv1alpha1.ProfileIntegration)
+			if newPI.ResourceVersion == oldPI.ResourceVersion {
+				// Periodic resync will send update events for all known ProfileIntegrations.
+				// Two different versions of the same ProfileIntegration will always have different RVs.
+				return
+			}
+			controller.enqueueAllProfiles(new)
+		},
+		DeleteFunc: controller.enqueueAllProfiles,
+	})
+
+	// Set up an event handler for when the ConfigMaps holding PodDefault
+	// templates change. The templates apply to every Profile, so all of
+	// them are enqueued for processing.
+	podDefaultTemplatesInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.enqueueAllProfiles,
+		UpdateFunc: func(old, new interface{}) {
+			newCM := new.(*v1.ConfigMap)
+			oldCM := old.(*v1.ConfigMap)
+			if newCM.ResourceVersion == oldCM.ResourceVersion {
+				// Periodic resync will send update events for all known ConfigMaps.
+				// Two different versions of the same ConfigMap will always have different RVs.
+				return
+			}
+			controller.enqueueAllProfiles(new)
+		},
+		DeleteFunc: controller.enqueueAllProfiles,
+	})
+
 	return controller
 }
 
@@ -291,7 +395,7 @@ func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) error {
 
 	// Wait for the caches to be synced before starting workers
 	klog.Info("Waiting for informer caches to sync")
-	if ok := cache.WaitForCacheSync(stopCh, c.podDefaultsSynced, c.secretsSynced, c.profilesSynced); !ok {
+	if ok := cache.WaitForCacheSync(stopCh, c.cachesSynced()...); !ok {
 		return fmt.Errorf("failed to wait for caches to sync")
 	}
 
@@ -300,6 +404,11 @@ func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) error {
 	for i := 0; i < threadiness; i++ {
 		go wait.Until(c.runWorker, time.Second, stopCh)
 	}
+	atomic.StoreInt32(&c.workersStarted, 1)
+
+	if c.vaultEntitySweep.Period > 0 {
+		go wait.Until(c.sweepVaultEntities, c.vaultEntitySweep.Period, stopCh)
+	}
 
 	klog.Info("Started workers")
 	<-stopCh
@@ -312,10 +421,28 @@ func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) error {
 // processNextWorkItem function in order to read and process a message on the
 // workqueue.
 func (c *Controller) runWorker() {
+	atomic.AddInt32(&c.workersRunning, 1)
+	defer atomic.AddInt32(&c.workersRunning, -1)
+
 	for c.processNextWorkItem() {
 	}
 }
 
+// cachesSynced returns the HasSynced function of every informer
+// used by the controller.
+func (c *Controller) cachesSynced() []cache.InformerSynced {
+	return []cache.InformerSynced{
+		c.podDefaultsSynced,
+		c.secretsSynced,
+		c.serviceAccountSynced,
+		c.roleBindingSynced,
+		c.profilesSynced,
+		c.envoyFiltersSynced,
+		c.profileIntegrationsSynced,
+		c.configMapsSynced,
+	}
+}
+
 // processNextWorkItem will read a single work item off the workqueue and
 // attempt to process it, by calling the syncHandler.
 func (c *Controller) processNextWorkItem() bool {
@@ -349,6 +476,10 @@ func (c *Controller) processNextWorkItem() bool {
 			utilruntime.HandleError(fmt.Errorf("expected string in workqueue but got %#v", obj))
 			return nil
 		}
+		// Track the item so a stuck worker can be detected.
+		c.processing.Store(key, time.Now())
+		defer c.processing.Delete(key)
+
 		// Run the syncHandler, passing it the namespace/name string of the
 		// Profile resource to be synced.
 		if err := c.syncHandler(key); err != nil {
@@ -388,116 +519,170 @@ func (c *Controller) syncHandler(key string) error {
 		return err
 	}
 
-	// Create an array to track all of the PodDefaults managed by this controller.
-	podDefaults := make([]*kubeflowv1alpha1.PodDefault, 0)
+	// The Profile is being deleted, clean up the state
+	// that isn't garbage collected by Kubernetes.
+	if !profile.DeletionTimestamp.IsZero() {
+		return c.finalizeProfile(profile)
+	}
 
-	// Create PodDefaults
-	for podDefaultName, newPodDefault := range PodDefaults {
-		if podDefaultName == "" {
-			// We choose to absorb the error here as the worker would requeue the
-			// resource otherwise. Instead, the next time the resource is updated
-			// the resource will be queued again.
-			utilruntime.HandleError(fmt.Errorf("%s: PodDefault name must be specified", key))
-			return nil
-		}
+	profile, err = c.addFinalizer(profile)
+	if err != nil {
+		return err
+	}
 
-		// Get the PodDefault with the name specified in Profile.spec
-		podDefault, err := c.podDefaultsLister.PodDefaults(profile.Name).Get(podDefaultName)
-		// If the resource doesn't exist, we'll create it
-		if errors.IsNotFound(err) {
-			podDefault, err = c.kubeflowclientset.KubeflowV1alpha1().PodDefaults(profile.Name).Create(context.TODO(), newPodDefault(profile), metav1.CreateOptions{})
-		}
+	// Run each step of the reconciliation, recording its outcome
+	// as a condition in the status of the Profile.
+	steps := []struct {
+		conditionType string
+		sync          func(profile *kubeflowv1.Profile) error
+	}{
+		{ProfileConditionPodDefaults, c.doPodDefaults},
+		{ProfileConditionImagePullSecret, c.doImagePullSecrets},
+		{ProfileConditionIntegrations, c.doProfileIntegrations},
+		{ProfileConditionEnvoyFilter, c.doPipelinesIstioEnvoyFilter},
+		{ProfileConditionVault, c.doSecretStore},
+	}
+
+	// The steps are independent of each other, so a failing step doesn't
+	// prevent the following ones from running. The errors are aggregated
+	// and the item is requeued so we can attempt processing again later.
+	conditions := make([]kubeflowv1.ProfileCondition, 0, len(steps))
+	errs := make([]error, 0)
+	for _, step := range steps {
+		start := time.Now()
+		err = step.sync(profile)
+		observeReconcileStep(step.conditionType, start, err)
+		conditions = append(conditions, newProfileCondition(step.conditionType, err))
 
-		// If an error occurs during Get/Create, we'll requeue the item so we can
-		// attempt processing again later. This could have been caused by a
-		// temporary network failure, or any other transient reason.
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("%s: %v", step.conditionType, err))
 		}
+	}
 
-		// If the PodDefault is not controlled by this Profile resource, we should log
-		// a warning to the event recorder and return error msg.
-		if !metav1.IsControlledBy(podDefault, profile) {
-			msg := fmt.Sprintf(MessageResourceExists, podDefault.Name)
-			c.recorder.Event(profile, v1.EventTypeWarning, ErrResourceExists, msg)
-			return fmt.Errorf(msg)
-		}
+	// The MinIO instances are reconciled independently too, each of
+	// them gets a condition besides the one of MinIO as a whole.
+	start := time.Now()
+	results, err := c.doMinIO(profile)
+	if err == nil {
+		err = results.Err()
+	}
+	observeReconcileStep(ProfileConditionMinIO, start, err)
+	conditions = append(conditions, newProfileCondition(ProfileConditionMinIO, err))
+	for _, instance := range results.Instances() {
+		conditions = append(conditions, newProfileCondition(minioInstanceConditionType(instance), results[instance]))
+	}
+
+	// Without results, the instances keep their last known condition
+	staleConditions := []string{}
+	if results != nil {
+		staleConditions = staleInstanceConditionTypes(profile.Status.Conditions, results)
+	}
+
+	if err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", ProfileConditionMinIO, err))
+	}
 
-		// Check against the expected PodDefault to see if the spec has changed.
-		expectedPodDefault := newPodDefault(profile)
-		if !reflect.DeepEqual(podDefault.Spec, expectedPodDefault.Spec) {
-			// Maintain the original meta information
-			expectedPodDefault.ObjectMeta = podDefault.ObjectMeta
+	// Finally, we update the status block of the Profile resource to reflect the
+	// current state of the world
+	if err = c.updateProfileStatus(profile, conditions, staleConditions); err != nil {
+		errs = append(errs, fmt.Errorf("status: %v", err))
+	}
 
-			klog.V(4).Infof("Profile %s PodDefault %s out of sync", profile.Name, podDefaultName)
-			podDefault, err = c.kubeflowclientset.KubeflowV1alpha1().PodDefaults(profile.Name).Update(context.TODO(), expectedPodDefault, metav1.UpdateOptions{})
+	if err = utilerrors.NewAggregate(errs); err != nil {
+		return err
+	}
+
+	c.recorder.Event(profile, v1.EventTypeNormal, SuccessSynced, MessageResourceSynced)
+	return nil
+}
+
+// doPodDefaults creates or updates the registered and templated PodDefaults
+// in the namespace of the Profile, and removes the templated PodDefaults
+// whose template is gone.
+func (c *Controller) doPodDefaults(profile *kubeflowv1.Profile) error {
+	configMaps, err := c.configMapsLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	podDefaults, err := newTemplatedPodDefaults(profile, configMaps)
+	if err != nil {
+		return err
+	}
+
+	for podDefaultName, newPodDefault := range PodDefaults {
+		if _, ok := podDefaults[podDefaultName]; ok {
+			return fmt.Errorf("PodDefault %q is both registered and templated", podDefaultName)
 		}
 
-		// If an error occurs during Update, we'll requeue the item so we can
-		// attempt processing again later. This could have been caused by a
-		// temporary network failure, or any other transient reason.
-		if err != nil {
-			return err
+		podDefaults[podDefaultName] = newPodDefault(profile)
+	}
+
+	errs := make([]error, 0)
+	for podDefaultName, podDefault := range podDefaults {
+		if err := c.doPodDefault(profile, podDefaultName, podDefault); err != nil {
+			errs = append(errs, err)
 		}
+	}
 
-		// Track PodDefaults associated with the profile.
-		podDefaults = append(podDefaults, podDefault)
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
 	}
 
-	// Add a secret into the namespace for the imagePullSecrets
-	var secret *v1.Secret
-	var serviceAccount *v1.ServiceAccount
-	secretName := "image-pull-secret"
-	serviceAccountName := "default-editor"
+	return c.removeStalePodDefaults(profile, podDefaults)
+}
 
-	if len(c.dockerConfigJSON) > 0 {
+// removeStalePodDefaults deletes the PodDefaults controlled by the Profile
+// which are neither registered nor templated anymore. PodDefaults that weren't
+// rendered from a template are only logged in dry-run mode.
+func (c *Controller) removeStalePodDefaults(profile *kubeflowv1.Profile, podDefaults map[string]*kubeflowv1alpha1.PodDefault) error {
+	existing, err := c.podDefaultsLister.PodDefaults(profile.Name).List(labels.Everything())
+	if err != nil {
+		return err
+	}
 
-		// Get the PodDefault with the name specified in Profile.spec
-		secret, err = c.secretsLister.Secrets(profile.Name).Get(secretName)
-		// If the resource doesn't exist, we'll create it
-		if errors.IsNotFound(err) {
-			secret, err = c.kubeclientset.CoreV1().Secrets(profile.Name).Create(context.TODO(), newImagePullSecret(profile, c.dockerConfigJSON), metav1.CreateOptions{})
+	errs := make([]error, 0)
+	for _, podDefault := range existing {
+		if _, ok := podDefaults[podDefault.Name]; ok || !metav1.IsControlledBy(podDefault, profile) {
+			continue
 		}
 
-		// If an error occurs during Get/Create, we'll requeue the item so we can
-		// attempt processing again later. This could have been caused by a
-		// temporary network failure, or any other transient reason.
-		if err != nil {
-			return err
+		reason := "which is no longer registered"
+		if templateName, templated := podDefault.Labels[PodDefaultTemplateLabel]; templated {
+			reason = fmt.Sprintf("whose template %q was removed", templateName)
 		}
 
-		// If the Deployment is not controlled by this Profile resource, we should log
-		// a warning to the event recorder and return error msg.
-		if !metav1.IsControlledBy(secret, profile) {
-			msg := fmt.Sprintf(MessageResourceExists, secret.Name)
-			c.recorder.Event(profile, v1.EventTypeWarning, ErrResourceExists, msg)
-			return fmt.Errorf(msg)
+		if c.podDefaultsGCDryRun {
+			klog.Infof("dry-run: would remove PodDefault %s/%s, %s", podDefault.Namespace, podDefault.Name, reason)
+			continue
 		}
 
-		// TODO: LOGIC TO COMPARE THE PODDEFAULTS AGAINST EXPECTED STATE.
-		//
-		// If this number of the replicas on the Profile resource is specified, and the
-		// number does not equal the current desired replicas on the Deployment, we
-		// should update the Deployment resource.
-		// if profile.Spec.Replicas != nil && *profile.Spec.Replicas != *deployment.Spec.Replicas {
-		// 	klog.V(4).Infof("Profile %s replicas: %d, deployment replicas: %d", name, *profile.Spec.Replicas, *deployment.Spec.Replicas)
-		// 	podDefault, err = c.kubeclientset.AppsV1().Deployments(profile.Namespace).Update(context.TODO(), newPodDefault(profile), metav1.UpdateOptions{})
-		// }
-
-		// If an error occurs during Update, we'll requeue the item so we can
-		// attempt processing again later. This could have been caused by a
-		// temporary network failure, or any other transient reason.
-		if err != nil {
-			return err
+		klog.Infof("removing PodDefault %s/%s, %s", podDefault.Namespace, podDefault.Name, reason)
+
+		err := c.kubeflowclientset.KubeflowV1alpha1().PodDefaults(podDefault.Namespace).Delete(context.TODO(), podDefault.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, err)
 		}
 	}
 
+	return utilerrors.NewAggregate(errs)
+}
+
+// doPodDefault creates or updates a single PodDefault.
+func (c *Controller) doPodDefault(profile *kubeflowv1.Profile, podDefaultName string, expectedPodDefault *kubeflowv1alpha1.PodDefault) error {
+	if podDefaultName == "" {
+		// We choose to absorb the error here as the worker would requeue the
+		// resource otherwise. Instead, the next time the resource is updated
+		// the resource will be queued again.
+		utilruntime.HandleError(fmt.Errorf("%s: PodDefault name must be specified", profile.Name))
+		return nil
+	}
+
 	// Get the PodDefault with the name specified in Profile.spec
-	serviceAccount, err = c.serviceAccountLister.ServiceAccounts(profile.Name).Get(serviceAccountName)
-	// If the resource doesn't exist, exit. We'll loop around and try again,
-	// it's likely the Kubeflow profile-controller hasn't created it yet.
+	podDefault, err := c.podDefaultsLister.PodDefaults(profile.Name).Get(podDefaultName)
+	// If the resource doesn't exist, we'll create it
 	if errors.IsNotFound(err) {
-		return err
+		podDefault, err = c.kubeflowclientset.KubeflowV1alpha1().PodDefaults(profile.Name).Create(context.TODO(), expectedPodDefault, metav1.CreateOptions{})
 	}
 
 	// If an error occurs during Get/Create, we'll requeue the item so we can
@@ -507,118 +692,220 @@ func (c *Controller) syncHandler(key string) error {
 		return err
 	}
 
-	found := -1
-	for indx, pullSecret := range serviceAccount.ImagePullSecrets {
-		if pullSecret.Name == secretName {
-			found = indx
-			break
-		}
+	// If the PodDefault is not controlled by this Profile resource, we should log
+	// a warning to the event recorder and return error msg.
+	if !metav1.IsControlledBy(podDefault, profile) {
+		msg := fmt.Sprintf(MessageResourceExists, podDefault.Name)
+		c.recorder.Event(profile, v1.EventTypeWarning, ErrResourceExists, msg)
+		return fmt.Errorf(msg)
 	}
 
-	if found < 0 && len(c.dockerConfigJSON) > 0 {
-		// Let's add the imagePullSecret to the ServiceAccount
-		serviceAccountCopy := serviceAccount.DeepCopy()
-		serviceAccountCopy.ImagePullSecrets = append(serviceAccountCopy.ImagePullSecrets, v1.LocalObjectReference{
-			Name: secretName,
-		})
-
-		serviceAccount, err = c.kubeclientset.CoreV1().ServiceAccounts(profile.Name).Update(context.TODO(), serviceAccountCopy, metav1.UpdateOptions{})
-		if err != nil {
-			return err
+	// Check against the expected PodDefault to see if the spec or the
+	// template it was rendered from have changed.
+	templateName := expectedPodDefault.Labels[PodDefaultTemplateLabel]
+	if !reflect.DeepEqual(podDefault.Spec, expectedPodDefault.Spec) || podDefault.Labels[PodDefaultTemplateLabel] != templateName {
+		// Maintain the original meta information
+		objectMeta := podDefault.ObjectMeta.DeepCopy()
+		if templateName != "" {
+			if objectMeta.Labels == nil {
+				objectMeta.Labels = make(map[string]string)
+			}
+			objectMeta.Labels[PodDefaultTemplateLabel] = templateName
 		}
-	} else if found >= 0 && len(c.dockerConfigJSON) == 0 {
-		// Let's remove it
-		serviceAccountCopy := serviceAccount.DeepCopy()
-		serviceAccountCopy.ImagePullSecrets = append(serviceAccountCopy.ImagePullSecrets[:found], serviceAccountCopy.ImagePullSecrets[found+1:]...)
 
-		serviceAccount, err = c.kubeclientset.CoreV1().ServiceAccounts(profile.Name).Update(context.TODO(), serviceAccountCopy, metav1.UpdateOptions{})
-		if err != nil {
-			return err
-		}
-	}
+		expectedPodDefault = expectedPodDefault.DeepCopy()
+		expectedPodDefault.ObjectMeta = *objectMeta
 
-	// Configure pachyderm role binding
-	err = c.doPachydermRoleBinding(profile)
+		klog.V(4).Infof("Profile %s PodDefault %s out of sync", profile.Name, podDefaultName)
+		_, err = c.kubeflowclientset.KubeflowV1alpha1().PodDefaults(profile.Name).Update(context.TODO(), expectedPodDefault, metav1.UpdateOptions{})
+	}
 
+	// If an error occurs during Update, we'll requeue the item so we can
+	// attempt processing again later. This could have been caused by a
+	// temporary network failure, or any other transient reason.
 	if err != nil {
 		return err
 	}
 
-	// Configure seldon role binding
-	err = c.doSeldonRoleBinding(profile)
+	return nil
+}
 
+// doSecretStore provisions the secret store of the Profile for its
+// owner and the subjects that have access to its namespace.
+func (c *Controller) doSecretStore(profile *kubeflowv1.Profile) error {
+	editors, viewers, err := c.vaultSubjects(profile)
 	if err != nil {
 		return err
 	}
 
-	// Configure argo role binding
-	err = c.doArgoRoleBinding(profile)
-
+	role, err := vaultKubernetesRole(profile)
 	if err != nil {
 		return err
 	}
 
-	// Configure pipelines-header EnvoyFilter
-	err = c.doPipelinesIstioEnvoyFilter(profile)
+	err = c.secretStore.ProvisionProfile(SecretStoreProfile{
+		Name:           profile.Name,
+		Owner:          profile.Spec.Owner.Name,
+		Editors:        editors,
+		Viewers:        viewers,
+		PolicyTier:     vaultPolicyTier(profile),
+		KubernetesRole: role,
+	})
 
-	if err != nil {
-		return err
+	if policyErr, ok := err.(*InvalidPolicyError); ok {
+		c.recorder.Event(profile, v1.EventTypeWarning, ErrInvalidVaultPolicy, fmt.Sprintf(MessageInvalidVaultPolicy, policyErr))
 	}
 
-	// Configure vault
-	//Get users that have access to the namespace
-	roleBindings, err := c.roleBindingLister.RoleBindings(profile.Name).List(labels.Nothing())
+	return err
+}
+
+// vaultSubjects returns the editors and the viewers of the Profile, from
+// the kubeflow-edit and kubeflow-view RoleBindings of its namespace.
+func (c *Controller) vaultSubjects(profile *kubeflowv1.Profile) (SecretStoreSubjects, SecretStoreSubjects, error) {
+	//Get the subjects that have access to the namespace
+	roleBindings, err := c.roleBindingLister.RoleBindings(profile.Name).List(labels.Everything())
 	if err != nil {
-		return err
+		return SecretStoreSubjects{}, SecretStoreSubjects{}, err
 	}
 
-	users := make([]string, 0)
+	editors := SecretStoreSubjects{}
+	viewers := SecretStoreSubjects{}
 	for _, currentRoleBinding := range roleBindings {
-		if currentRoleBinding.RoleRef.Name == "kubeflow-edit" {
-			for _, subject := range currentRoleBinding.Subjects {
-				if subject.Kind == "User" {
-					users = append(users, subject.Name)
+		var subjects *SecretStoreSubjects
+		switch currentRoleBinding.RoleRef.Name {
+		case "kubeflow-edit":
+			subjects = &editors
+		case "kubeflow-view":
+			subjects = &viewers
+		default:
+			continue
+		}
+
+		for _, subject := range currentRoleBinding.Subjects {
+			switch subject.Kind {
+			case rbacv1.UserKind:
+				subjects.Users = appendUnique(subjects.Users, subject.Name)
+			case rbacv1.GroupKind:
+				subjects.Groups = appendUnique(subjects.Groups, subject.Name)
+			case rbacv1.ServiceAccountKind:
+				namespace := subject.Namespace
+				if namespace == "" {
+					namespace = profile.Name
 				}
+				subjects.ServiceAccounts = appendUnique(subjects.ServiceAccounts, namespace+"/"+subject.Name)
 			}
 		}
 	}
 
-	err = c.vaultConfigurer.ConfigVaultForProfile(profile.Name, profile.Spec.Owner.Name, users)
+	return editors, viewers, nil
+}
 
-	// If an error occurs during Update, we'll requeue the item so we can
-	// attempt processing again later. This could have been caused by a
-	// temporary network failure, or any other transient reason.
-	if err != nil {
-		return err
+// vaultPolicyTier returns the Vault policy tier selected by the label
+// of the Profile, or by its annotation.
+func vaultPolicyTier(profile *kubeflowv1.Profile) string {
+	if tier, ok := profile.Labels[VaultPolicyTierLabel]; ok {
+		return tier
 	}
 
-	// Configure MinIO
-	// Autocreate MinIO buckets for the user
-	if err = c.minio.CreateBucketsForProfile(profile.Name); err != nil {
-		return err
+	return profile.Annotations[VaultPolicyTierLabel]
+}
+
+// vaultKubernetesRole returns the configuration of the Kubernetes auth
+// role set by the annotations of the Profile.
+func vaultKubernetesRole(profile *kubeflowv1.Profile) (KubernetesRoleConfig, error) {
+	role := KubernetesRoleConfig{
+		ServiceAccounts: splitList(profile.Annotations[VaultRoleServiceAccountsAnnotation]),
+		Audience:        profile.Annotations[VaultRoleAudienceAnnotation],
+		Policies:        splitList(profile.Annotations[VaultRolePoliciesAnnotation]),
 	}
 
-	// Finally, we update the status block of the Profile resource to reflect the
-	// current state of the world
-	err = c.updateProfileStatus(profile, podDefaults, secret, serviceAccount)
+	var err error
+	if value, ok := profile.Annotations[VaultRoleTokenTTLAnnotation]; ok {
+		if role.TokenTTL, err = time.ParseDuration(value); err != nil {
+			return role, fmt.Errorf("%s: %v", VaultRoleTokenTTLAnnotation, err)
+		}
+	}
+
+	if value, ok := profile.Annotations[VaultRoleTokenMaxTTLAnnotation]; ok {
+		if role.TokenMaxTTL, err = time.ParseDuration(value); err != nil {
+			return role, fmt.Errorf("%s: %v", VaultRoleTokenMaxTTLAnnotation, err)
+		}
+	}
+
+	return role, nil
+}
+
+// minioBucketConfig returns the configuration of the bucket of the
+// Profile, the default one overridden by the annotations of the Profile.
+func minioBucketConfig(profile *kubeflowv1.Profile, defaults MinIOBucketConfig) (MinIOBucketConfig, error) {
+	config := defaults
+
+	if value, ok := profile.Annotations[MinIOQuotaAnnotation]; ok {
+		quota, err := resource.ParseQuantity(value)
+		if err != nil {
+			return config, fmt.Errorf("%s: %v", MinIOQuotaAnnotation, err)
+		}
+		config.Quota = quota.Value()
+	}
+
+	var err error
+	if value, ok := profile.Annotations[MinIOVersioningAnnotation]; ok {
+		if config.Versioning, err = strconv.ParseBool(value); err != nil {
+			return config, fmt.Errorf("%s: %v", MinIOVersioningAnnotation, err)
+		}
+	}
+
+	if value, ok := profile.Annotations[MinIOObjectLockAnnotation]; ok {
+		if config.ObjectLock, err = strconv.ParseBool(value); err != nil {
+			return config, fmt.Errorf("%s: %v", MinIOObjectLockAnnotation, err)
+		}
+	}
+
+	if value, ok := profile.Annotations[MinIOExpirationAnnotation]; ok {
+		if config.Expiration, err = ParseMinIOExpiration(value); err != nil {
+			return config, fmt.Errorf("%s: %v", MinIOExpirationAnnotation, err)
+		}
+	}
+
+	return config, nil
+}
+
+// doMinIO autocreates the MinIO buckets for the user, and reconciles
+// the configuration of the bucket of the Profile. It returns the outcome
+// in each instance.
+func (c *Controller) doMinIO(profile *kubeflowv1.Profile) (MinIOResults, error) {
+	buckets, err := minioBucketConfig(profile, c.minioBuckets)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	c.recorder.Event(profile, v1.EventTypeNormal, SuccessSynced, MessageResourceSynced)
-	return nil
+	return c.objectStorage.CreateBucketsForProfile(MinIOProfile{
+		Name:    profile.Name,
+		Owner:   profile.Spec.Owner.Name,
+		Buckets: buckets,
+	}), nil
 }
 
-func (c *Controller) updateProfileStatus(profile *kubeflowv1.Profile, podDefaults []*kubeflowv1alpha1.PodDefault, secret *v1.Secret, serviceAccount *v1.ServiceAccount) error {
+// updateProfileStatus merges the conditions into the status of the Profile,
+// and removes the stale ones, writing it through the status subresource only
+// when something changed.
+func (c *Controller) updateProfileStatus(profile *kubeflowv1.Profile, conditions []kubeflowv1.ProfileCondition, staleConditions []string) error {
 	// NEVER modify objects from the store. It's a read-only, local cache.
 	// You can use DeepCopy() to make a deep copy of original object and modify this copy
 	// Or create a copy manually for better performance
 	profileCopy := profile.DeepCopy()
-	// If the CustomResourceSubresources feature gate is not enabled,
-	// we must use Update instead of UpdateStatus to update the Status block of the Profile resource.
+	profileCopy.Status.Conditions = removeProfileConditions(profileCopy.Status.Conditions, staleConditions)
+	for _, condition := range conditions {
+		profileCopy.Status.Conditions = setProfileCondition(profileCopy.Status.Conditions, condition)
+	}
+
+	if reflect.DeepEqual(profile.Status, profileCopy.Status) {
+		return nil
+	}
+
 	// UpdateStatus will not allow changes to the Spec of the resource,
 	// which is ideal for ensuring nothing other than resource status has been updated.
-	_, err := c.kubeflowclientset.KubeflowV1().Profiles().Update(context.TODO(), profileCopy, metav1.UpdateOptions{})
+	_, err := c.kubeflowclientset.KubeflowV1().Profiles().UpdateStatus(context.TODO(), profileCopy, metav1.UpdateOptions{})
 	return err
 }
 
@@ -635,6 +922,36 @@ func (c *Controller) enqueueProfile(obj interface{}) {
 	c.workqueue.Add(key)
 }
 
+// handleSecret enqueues every Profile when the source of a registry
+// credential changes, and otherwise hands the Secret over to handleObject.
+func (c *Controller) handleSecret(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err == nil {
+		for _, credential := range c.registryCredentials {
+			if credential.Source != "" && key == credential.Source {
+				c.enqueueAllProfiles(obj)
+				return
+			}
+		}
+	}
+
+	c.handleObject(obj)
+}
+
+// enqueueAllProfiles puts every Profile onto the work queue, for changes
+// of resources which apply to all of them.
+func (c *Controller) enqueueAllProfiles(obj interface{}) {
+	profiles, err := c.profilesLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+
+	for _, profile := range profiles {
+		c.enqueueProfile(profile)
+	}
+}
+
 // handleObject will take any resource implementing metav1.Object and attempt
 // to find the Profile resource that 'owns' it. It does this by looking at the
 // objects metadata.ownerReferences field for an appropriate OwnerReference.
@@ -674,228 +991,3 @@ func (c *Controller) handleObject(obj interface{}) {
 		return
 	}
 }
-
-func newImagePullSecret(profile *kubeflowv1.Profile, dockerConfigJSON []byte) *v1.Secret {
-	return &v1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "image-pull-secret",
-			Namespace: profile.Name,
-			OwnerReferences: []metav1.OwnerReference{
-				*metav1.NewControllerRef(profile, kubeflowv1.SchemeGroupVersion.WithKind("Profile")),
-			},
-		},
-		Type: v1.SecretTypeDockerConfigJson,
-		Data: map[string][]byte{
-			".dockerconfigjson": dockerConfigJSON,
-		},
-	}
-}
-
-func (c *Controller) doPachydermRoleBinding(profile *kubeflowv1.Profile) error {
-	roleBindingName := fmt.Sprintf("profile-%s", profile.Name)
-
-	// Get the PodDefault with the name specified in Profile.spec
-	roleBinding, err := c.roleBindingLister.RoleBindings(pachydermNamespace).Get(roleBindingName)
-	// If the resource doesn't exist, we'll create it
-	if errors.IsNotFound(err) {
-		roleBinding, err = c.kubeclientset.RbacV1().RoleBindings(pachydermNamespace).Create(context.TODO(), newPachydermRoleBinding(profile, roleBindingName), metav1.CreateOptions{})
-	}
-
-	// If an error occurs during Get/Create, we'll requeue the item so we can
-	// attempt processing again later. This could have been caused by a
-	// temporary network failure, or any other transient reason.
-	if err != nil {
-		return err
-	}
-
-	// If the Deployment is not controlled by this Profile resource, we should log
-	// a warning to the event recorder and return error msg.
-	if !metav1.IsControlledBy(roleBinding, profile) {
-		msg := fmt.Sprintf(MessageResourceExists, roleBinding.Name)
-		c.recorder.Event(profile, v1.EventTypeWarning, ErrResourceExists, msg)
-		return fmt.Errorf(msg)
-	}
-
-	// TODO: LOGIC TO COMPARE THE PODDEFAULTS AGAINST EXPECTED STATE.
-	//
-	// If this number of the replicas on the Profile resource is specified, and the
-	// number does not equal the current desired replicas on the Deployment, we
-	// should update the Deployment resource.
-	// if profile.Spec.Replicas != nil && *profile.Spec.Replicas != *deployment.Spec.Replicas {
-	// 	klog.V(4).Infof("Profile %s replicas: %d, deployment replicas: %d", name, *profile.Spec.Replicas, *deployment.Spec.Replicas)
-	// 	podDefault, err = c.kubeclientset.AppsV1().Deployments(profile.Namespace).Update(context.TODO(), newPodDefault(profile), metav1.UpdateOptions{})
-	// }
-
-	// If an error occurs during Update, we'll requeue the item so we can
-	// attempt processing again later. This could have been caused by a
-	// temporary network failure, or any other transient reason.
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func newPachydermRoleBinding(profile *kubeflowv1.Profile, name string) *rbacv1.RoleBinding {
-	return &rbacv1.RoleBinding{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: pachydermNamespace,
-			OwnerReferences: []metav1.OwnerReference{
-				*metav1.NewControllerRef(profile, kubeflowv1.SchemeGroupVersion.WithKind("Profile")),
-			},
-		},
-		RoleRef: rbacv1.RoleRef{
-			APIGroup: "rbac.authorization.k8s.io",
-			Kind:     "Role",
-			Name:     "pachyderm-role",
-		},
-		Subjects: []rbacv1.Subject{
-			{
-				Kind:      rbacv1.ServiceAccountKind,
-				Name:      "default-editor",
-				Namespace: profile.Name,
-			},
-		},
-	}
-}
-
-func (c *Controller) doSeldonRoleBinding(profile *kubeflowv1.Profile) error {
-	roleBindingName := "seldon-user"
-
-	// Get the PodDefault with the name specified in Profile.spec
-	roleBinding, err := c.roleBindingLister.RoleBindings(profile.Name).Get(roleBindingName)
-	// If the resource doesn't exist, we'll create it
-	if errors.IsNotFound(err) {
-		roleBinding, err = c.kubeclientset.RbacV1().RoleBindings(profile.Name).Create(context.TODO(), newSeldonRoleBinding(profile, roleBindingName), metav1.CreateOptions{})
-	}
-
-	// If an error occurs during Get/Create, we'll requeue the item so we can
-	// attempt processing again later. This could have been caused by a
-	// temporary network failure, or any other transient reason.
-	if err != nil {
-		return err
-	}
-
-	// If the Deployment is not controlled by this Profile resource, we should log
-	// a warning to the event recorder and return error msg.
-	if !metav1.IsControlledBy(roleBinding, profile) {
-		msg := fmt.Sprintf(MessageResourceExists, roleBinding.Name)
-		c.recorder.Event(profile, v1.EventTypeWarning, ErrResourceExists, msg)
-		return fmt.Errorf(msg)
-	}
-
-	// TODO: LOGIC TO COMPARE THE PODDEFAULTS AGAINST EXPECTED STATE.
-	//
-	// If this number of the replicas on the Profile resource is specified, and the
-	// number does not equal the current desired replicas on the Deployment, we
-	// should update the Deployment resource.
-	// if profile.Spec.Replicas != nil && *profile.Spec.Replicas != *deployment.Spec.Replicas {
-	// 	klog.V(4).Infof("Profile %s replicas: %d, deployment replicas: %d", name, *profile.Spec.Replicas, *deployment.Spec.Replicas)
-	// 	podDefault, err = c.kubeclientset.AppsV1().Deployments(profile.Namespace).Update(context.TODO(), newPodDefault(profile), metav1.UpdateOptions{})
-	// }
-
-	// If an error occurs during Update, we'll requeue the item so we can
-	// attempt processing again later. This could have been caused by a
-	// temporary network failure, or any other transient reason.
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func newSeldonRoleBinding(profile *kubeflowv1.Profile, name string) *rbacv1.RoleBinding {
-	return &rbacv1.RoleBinding{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: profile.Name,
-			OwnerReferences: []metav1.OwnerReference{
-				*metav1.NewControllerRef(profile, kubeflowv1.SchemeGroupVersion.WithKind("Profile")),
-			},
-		},
-		RoleRef: rbacv1.RoleRef{
-			APIGroup: "rbac.authorization.k8s.io",
-			Kind:     "ClusterRole",
-			Name:     "seldon-user",
-		},
-		Subjects: []rbacv1.Subject{
-			{
-				Kind:      rbacv1.ServiceAccountKind,
-				Name:      "default-editor",
-				Namespace: profile.Name,
-			},
-			profile.Spec.Owner,
-		},
-	}
-}
-
-func (c *Controller) doArgoRoleBinding(profile *kubeflowv1.Profile) error {
-	roleBindingName := "default-editor-argo"
-
-	// Get the PodDefault with the name specified in Profile.spec
-	roleBinding, err := c.roleBindingLister.RoleBindings(profile.Name).Get(roleBindingName)
-	// If the resource doesn't exist, we'll create it
-	if errors.IsNotFound(err) {
-		roleBinding, err = c.kubeclientset.RbacV1().RoleBindings(profile.Name).Create(context.TODO(), newArgoRoleBinding(profile, roleBindingName), metav1.CreateOptions{})
-	}
-
-	// If an error occurs during Get/Create, we'll requeue the item so we can
-	// attempt processing again later. This could have been caused by a
-	// temporary network failure, or any other transient reason.
-	if err != nil {
-		return err
-	}
-
-	// If the Deployment is not controlled by this Profile resource, we should log
-	// a warning to the event recorder and return error msg.
-	if !metav1.IsControlledBy(roleBinding, profile) {
-		msg := fmt.Sprintf(MessageResourceExists, roleBinding.Name)
-		c.recorder.Event(profile, v1.EventTypeWarning, ErrResourceExists, msg)
-		return fmt.Errorf(msg)
-	}
-
-	// TODO: LOGIC TO COMPARE THE PODDEFAULTS AGAINST EXPECTED STATE.
-	//
-	// If this number of the replicas on the Profile resource is specified, and the
-	// number does not equal the current desired replicas on the Deployment, we
-	// should update the Deployment resource.
-	// if profile.Spec.Replicas != nil && *profile.Spec.Replicas != *deployment.Spec.Replicas {
-	// 	klog.V(4).Infof("Profile %s replicas: %d, deployment replicas: %d", name, *profile.Spec.Replicas, *deployment.Spec.Replicas)
-	// 	podDefault, err = c.kubeclientset.AppsV1().Deployments(profile.Namespace).Update(context.TODO(), newPodDefault(profile), metav1.UpdateOptions{})
-	// }
-
-	// If an error occurs during Update, we'll requeue the item so we can
-	// attempt processing again later. This could have been caused by a
-	// temporary network failure, or any other transient reason.
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func newArgoRoleBinding(profile *kubeflowv1.Profile, name string) *rbacv1.RoleBinding {
-	return &rbacv1.RoleBinding{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: profile.Name,
-			OwnerReferences: []metav1.OwnerReference{
-				*metav1.NewControllerRef(profile, kubeflowv1.SchemeGroupVersion.WithKind("Profile")),
-			},
-		},
-		RoleRef: rbacv1.RoleRef{
-			APIGroup: "rbac.authorization.k8s.io",
-			Kind:     "ClusterRole",
-			Name:     "argo",
-		},
-		Subjects: []rbacv1.Subject{
-			{
-				Kind:      rbacv1.ServiceAccountKind,
-				Name:      "default-editor",
-				Namespace: profile.Name,
-			},
-			profile.Spec.Owner,
-		},
-	}
-}
@@ -2,12 +2,23 @@ package main
 
 import (
 	"fmt"
+	"sort"
 
 	kubeflowv1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1"
 	kubeflowv1alpha1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1alpha1"
+	kubeflowscheme "github.com/StatCan/kubeflow-controller/pkg/generated/clientset/versioned/scheme"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// PodDefaultTemplatesLabel selects the ConfigMaps holding PodDefault templates.
+	PodDefaultTemplatesLabel = "kubeflow-controller.statcan.gc.ca/poddefaults"
+	// PodDefaultTemplateLabel is set on the PodDefaults rendered from a template
+	// to the name of the ConfigMap holding the template.
+	PodDefaultTemplateLabel = "kubeflow-controller.statcan.gc.ca/poddefault-template"
+)
+
 // NewPodDefaultFunc represents the function called to create a new PodDefault.
 type NewPodDefaultFunc func(profile *kubeflowv1.Profile) *kubeflowv1alpha1.PodDefault
 
@@ -27,27 +38,54 @@ func RegisterPodDefault(name string, callback NewPodDefaultFunc) error {
 	return nil
 }
 
-func init() {
-	RegisterPodDefault("minio-mounts", func(profile *kubeflowv1.Profile) *kubeflowv1alpha1.PodDefault {
-		return &kubeflowv1alpha1.PodDefault{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "minio-mounts",
-				Namespace: profile.Name,
-				OwnerReferences: []metav1.OwnerReference{
-					*metav1.NewControllerRef(profile, kubeflowv1.SchemeGroupVersion.WithKind("Profile")),
-				},
-			},
-			Spec: kubeflowv1alpha1.PodDefaultSpec{
-				Desc: "Mount MinIO storage into the minio/ folder",
-				Selector: metav1.LabelSelector{
-					MatchLabels: map[string]string{
-						"minio-mounts": "true",
-					},
-				},
-				Annotations: map[string]string{
-					"data.statcan.gc.ca/inject-boathouse": "true",
-				},
-			},
+// newTemplatedPodDefaults renders the PodDefault templates held by the
+// ConfigMaps for the Profile. Each key of a ConfigMap is a PodDefault
+// manifest, rendered as a Go template with the name of the Profile
+// ({{ .ProfileName }}) and its owner ({{ .Owner }}).
+func newTemplatedPodDefaults(profile *kubeflowv1.Profile, configMaps []*v1.ConfigMap) (map[string]*kubeflowv1alpha1.PodDefault, error) {
+	data := newProfileTemplateData(profile)
+	decoder := kubeflowscheme.Codecs.UniversalDeserializer()
+
+	podDefaults := make(map[string]*kubeflowv1alpha1.PodDefault)
+	for _, configMap := range configMaps {
+		keys := make([]string, 0, len(configMap.Data))
+		for key := range configMap.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			rendered, err := renderProfileTemplate(configMap.Data[key], data)
+			if err != nil {
+				return nil, fmt.Errorf("configmap %s/%s key %q: %v", configMap.Namespace, configMap.Name, key, err)
+			}
+
+			obj, _, err := decoder.Decode([]byte(rendered), nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("configmap %s/%s key %q: %v", configMap.Namespace, configMap.Name, key, err)
+			}
+
+			podDefault, ok := obj.(*kubeflowv1alpha1.PodDefault)
+			if !ok {
+				return nil, fmt.Errorf("configmap %s/%s key %q: expected a PodDefault, got %T", configMap.Namespace, configMap.Name, key, obj)
+			}
+
+			if _, ok := podDefaults[podDefault.Name]; ok {
+				return nil, fmt.Errorf("configmap %s/%s key %q: PodDefault %q is defined more than once", configMap.Namespace, configMap.Name, key, podDefault.Name)
+			}
+
+			podDefault.Namespace = profile.Name
+			if podDefault.Labels == nil {
+				podDefault.Labels = make(map[string]string)
+			}
+			podDefault.Labels[PodDefaultTemplateLabel] = configMap.Name
+			podDefault.OwnerReferences = []metav1.OwnerReference{
+				*metav1.NewControllerRef(profile, kubeflowv1.SchemeGroupVersion.WithKind("Profile")),
+			}
+
+			podDefaults[podDefault.Name] = podDefault
 		}
-	})
+	}
+
+	return podDefaults, nil
 }
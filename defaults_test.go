@@ -0,0 +1,165 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	kubeflowv1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1"
+	kubeflowv1alpha1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1alpha1"
+	"github.com/StatCan/kubeflow-controller/pkg/generated/clientset/versioned/fake"
+	v1alpha1listers "github.com/StatCan/kubeflow-controller/pkg/generated/listers/kubeflowcontroller/v1alpha1"
+)
+
+const testPodDefaultTemplate = `
+apiVersion: kubeflow.org/v1alpha1
+kind: PodDefault
+metadata:
+  name: {{ .ProfileName }}-minio
+  namespace: somewhere-else
+spec:
+  desc: MinIO of {{ .Owner }}
+  selector:
+    matchLabels:
+      minio-mount: "true"
+  env:
+  - name: PROFILE
+    value: {{ .ProfileName }}
+`
+
+// Tests that the templates are rendered into PodDefaults of the Profile
+func TestNewTemplatedPodDefaults(t *testing.T) {
+	profile := newTestProfile()
+	configMaps := []*v1.ConfigMap{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "minio", Namespace: "daaas"},
+			Data: map[string]string{
+				"minio.yaml": testPodDefaultTemplate,
+			},
+		},
+	}
+
+	podDefaults, err := newTemplatedPodDefaults(profile, configMaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	podDefault, ok := podDefaults["test-minio"]
+	if !ok || len(podDefaults) != 1 {
+		t.Fatalf("Expected the test-minio PodDefault, got %v", podDefaults)
+	}
+
+	if podDefault.Namespace != "test" {
+		t.Errorf("Expected the namespace of the Profile, got %q", podDefault.Namespace)
+	}
+
+	if podDefault.Spec.Desc != "MinIO of owner@example.com" {
+		t.Errorf("Unexpected description %q", podDefault.Spec.Desc)
+	}
+
+	if len(podDefault.Spec.Env) != 1 || podDefault.Spec.Env[0].Value != "test" {
+		t.Errorf("Unexpected env %v", podDefault.Spec.Env)
+	}
+
+	if podDefault.Labels[PodDefaultTemplateLabel] != "minio" {
+		t.Errorf("Expected the template label, got %v", podDefault.Labels)
+	}
+
+	if !metav1.IsControlledBy(podDefault, profile) {
+		t.Error("Expected the PodDefault to be controlled by the Profile")
+	}
+}
+
+func TestNewTemplatedPodDefaults_invalid(t *testing.T) {
+	invalidTemplates := map[string]map[string]string{
+		"unknown field": {"a.yaml": "metadata:\n  name: {{ .Unknown }}\n"},
+		"not a PodDefault": {"a.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+`},
+		"duplicate": {
+			"a.yaml": testPodDefaultTemplate,
+			"b.yaml": testPodDefaultTemplate,
+		},
+	}
+
+	for name, data := range invalidTemplates {
+		configMaps := []*v1.ConfigMap{
+			{ObjectMeta: metav1.ObjectMeta{Name: "minio", Namespace: "daaas"}, Data: data},
+		}
+
+		if _, err := newTemplatedPodDefaults(newTestProfile(), configMaps); err == nil {
+			t.Errorf("Expected the %s template to be rejected", name)
+		}
+	}
+}
+
+// Tests that the dry-run only logs the stale PodDefaults, the ones of
+// removed templates included, and that they're deleted otherwise
+func TestRemoveStalePodDefaults(t *testing.T) {
+	profile := newTestProfile()
+	controllerRef := *metav1.NewControllerRef(profile, kubeflowv1.SchemeGroupVersion.WithKind("Profile"))
+
+	newPodDefault := func(name string, labels map[string]string, controlled bool) *kubeflowv1alpha1.PodDefault {
+		podDefault := &kubeflowv1alpha1.PodDefault{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: labels},
+		}
+		if controlled {
+			podDefault.OwnerReferences = []metav1.OwnerReference{controllerRef}
+		}
+		return podDefault
+	}
+
+	podDefaults := []*kubeflowv1alpha1.PodDefault{
+		newPodDefault("registered", nil, true),
+		newPodDefault("unregistered", nil, true),
+		newPodDefault("templated", map[string]string{PodDefaultTemplateLabel: "minio"}, true),
+		newPodDefault("foreign", nil, false),
+	}
+
+	tests := map[bool][]string{
+		true:  {},
+		false: {"templated", "unregistered"},
+	}
+
+	for dryRun, expected := range tests {
+		objects := make([]runtime.Object, 0, len(podDefaults))
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		for _, podDefault := range podDefaults {
+			objects = append(objects, podDefault.DeepCopy())
+			indexer.Add(podDefault)
+		}
+
+		kubeflowclient := fake.NewSimpleClientset(objects...)
+		c := &Controller{
+			kubeflowclientset:   kubeflowclient,
+			podDefaultsLister:   v1alpha1listers.NewPodDefaultLister(indexer),
+			podDefaultsGCDryRun: dryRun,
+		}
+
+		err := c.removeStalePodDefaults(profile, map[string]*kubeflowv1alpha1.PodDefault{"registered": podDefaults[0]})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		deleted := make([]string, 0)
+		for _, action := range kubeflowclient.Actions() {
+			if action.GetVerb() == "delete" {
+				deleted = append(deleted, action.(k8stesting.DeleteAction).GetName())
+			}
+		}
+		sort.Strings(deleted)
+
+		if !reflect.DeepEqual(deleted, expected) {
+			t.Errorf("dry-run=%v: expected %v to be deleted, got %v", dryRun, expected, deleted)
+		}
+	}
+}
@@ -12,6 +12,7 @@ metadata:
   labels:
     apps.kubernetes.io/name: profile-configurator
 spec:
+  replicas: 2
   selector:
     matchLabels:
       apps.kubernetes.io/name: profile-configurator
@@ -24,6 +25,8 @@ spec:
         vault.hashicorp.com/agent-configmap: "profile-configurator-vault-agent-config"
         vault.hashicorp.com/agent-pre-populate: "false"
         sidecar.istio.io/inject: 'false'
+        prometheus.io/scrape: 'true'
+        prometheus.io/port: '9090'
     spec:
       serviceAccountName: profile-configurator
       imagePullSecrets:
@@ -31,16 +34,33 @@ spec:
       containers:
       - name: profile-configurator
         image: k8scc01covidacr.azurecr.io/kubeflow-controller:${IMAGE_SHA}
+        ports:
+        - name: metrics
+          containerPort: 9090
+        - name: health
+          containerPort: 8081
+        livenessProbe:
+          httpGet:
+            path: /healthz
+            port: health
+          initialDelaySeconds: 15
+          periodSeconds: 20
+        readinessProbe:
+          httpGet:
+            path: /readyz
+            port: health
+          periodSeconds: 10
         resources:
           limits:
             memory: "128Mi"
             cpu: "500m"
         env:
-          - name: IMAGE_PULL_SECRET
+          - name: POD_NAMESPACE
             valueFrom:
-              secretKeyRef:
-                name: k8scc01covidacr-registry-connection
-                key: .dockerconfigjson
+              fieldRef:
+                fieldPath: metadata.namespace
+          - name: IMAGE_PULL_SECRET_SOURCE
+            value: daaas/k8scc01covidacr-registry-connection
           - name: VAULT_AGENT_ADDR
             value: http://127.0.0.1:8100
           - name: MINIO_INSTANCES
@@ -49,6 +69,24 @@ spec:
             value: ${VAULT_AUTH_PATH}
           - name: OIDC_AUTH_ACCESSOR
             value: ${OIDC_AUTH_ACCESSOR}
+          - name: VAULT_POLICY_TEMPLATES_DIR
+            value: /etc/profile-configurator/vault-policies
+          - name: MINIO_BUCKET_LAYOUTS
+            value: /etc/profile-configurator/minio/bucket-layouts.yaml
+        volumeMounts:
+          - name: vault-policies
+            mountPath: /etc/profile-configurator/vault-policies
+            readOnly: true
+          - name: minio
+            mountPath: /etc/profile-configurator/minio
+            readOnly: true
+      volumes:
+        - name: vault-policies
+          configMap:
+            name: profile-configurator-vault-policies
+        - name: minio
+          configMap:
+            name: profile-configurator-minio
 ---
 apiVersion: rbac.authorization.k8s.io/v1
 kind: ClusterRole
@@ -60,6 +98,7 @@ rules:
   resources:
     - 'secrets'
     - 'serviceaccounts'
+    - 'configmaps'
   verbs:
     - watch
     - list
@@ -78,6 +117,8 @@ rules:
     - create
     - update
     - delete
+  # The image pull secrets: image-pull-secret and
+  # the name of each of the REGISTRY_CREDENTIALS
   resourceNames:
     - image-pull-secret
 - apiGroups:
@@ -89,6 +130,7 @@ rules:
     - update
   resourceNames:
     - default-editor
+    - default-viewer
 - apiGroups:
     - 'kubeflow.org'
   resources:
@@ -100,6 +142,22 @@ rules:
     - watch
     - create
     - update
+    - delete
+- apiGroups:
+    - 'kubeflow.org'
+  resources:
+    - 'profileintegrations'
+  verbs:
+    - get
+    - list
+    - watch
+- apiGroups:
+    - 'kubeflow.org'
+  resources:
+    - 'profiles/status'
+  verbs:
+    - get
+    - update
 - apiGroups:
     - ''
   resources:
@@ -145,6 +203,35 @@ roleRef:
 ---
 apiVersion: rbac.authorization.k8s.io/v1
 kind: Role
+metadata:
+  name: profile-configurator-leader-election
+  namespace: daaas
+rules:
+- apiGroups:
+    - coordination.k8s.io
+  resources:
+    - 'leases'
+  verbs:
+    - get
+    - create
+    - update
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: profile-configurator-leader-election
+  namespace: daaas
+subjects:
+- kind: ServiceAccount
+  name: profile-configurator
+  namespace: daaas
+roleRef:
+  kind: Role
+  name: profile-configurator-leader-election
+  apiGroup: rbac.authorization.k8s.io
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
 metadata:
   name: pachyderm-profile-configurator
   namespace: pachyderm
@@ -258,3 +345,203 @@ roleRef:
   kind: ClusterRole
   name: argo
   apiGroup: rbac.authorization.k8s.io
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: profileintegrations.kubeflow.org
+spec:
+  group: kubeflow.org
+  names:
+    kind: ProfileIntegration
+    listKind: ProfileIntegrationList
+    plural: profileintegrations
+    singular: profileintegration
+  scope: Cluster
+  versions:
+  - name: v1alpha1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              roleBindings:
+                type: array
+                items:
+                  type: object
+                  required:
+                  - name
+                  - roleRef
+                  properties:
+                    name:
+                      type: string
+                    namespace:
+                      type: string
+                    roleRef:
+                      type: object
+                      required:
+                      - apiGroup
+                      - kind
+                      - name
+                      properties:
+                        apiGroup:
+                          type: string
+                        kind:
+                          type: string
+                        name:
+                          type: string
+                    subjects:
+                      type: array
+                      items:
+                        type: object
+                        properties:
+                          owner:
+                            type: boolean
+                          kind:
+                            type: string
+                          apiGroup:
+                            type: string
+                          name:
+                            type: string
+                          namespace:
+                            type: string
+---
+apiVersion: kubeflow.org/v1alpha1
+kind: ProfileIntegration
+metadata:
+  name: pachyderm
+spec:
+  roleBindings:
+  - name: 'profile-{{ .ProfileName }}'
+    namespace: pachyderm
+    roleRef:
+      apiGroup: rbac.authorization.k8s.io
+      kind: Role
+      name: pachyderm-role
+    subjects:
+    - kind: ServiceAccount
+      name: default-editor
+---
+apiVersion: kubeflow.org/v1alpha1
+kind: ProfileIntegration
+metadata:
+  name: seldon
+spec:
+  roleBindings:
+  - name: seldon-user
+    roleRef:
+      apiGroup: rbac.authorization.k8s.io
+      kind: ClusterRole
+      name: seldon-user
+    subjects:
+    - kind: ServiceAccount
+      name: default-editor
+    - owner: true
+---
+apiVersion: kubeflow.org/v1alpha1
+kind: ProfileIntegration
+metadata:
+  name: argo
+spec:
+  roleBindings:
+  - name: default-editor-argo
+    roleRef:
+      apiGroup: rbac.authorization.k8s.io
+      kind: ClusterRole
+      name: argo
+    subjects:
+    - kind: ServiceAccount
+      name: default-editor
+    - owner: true
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: profile-configurator-vault-policies
+  namespace: daaas
+data:
+  standard.hcl: |
+    #
+    # Policy for Kubeflow profile: {{ .ProfileName }}
+    # (policy managed by the custom Kubeflow Profiles controller)
+    #
+
+    # Grant full access to the KV created for this profile
+    path "kv_{{ .ProfileName }}/*" {
+    	capabilities = ["create", "update", "delete", "read", "list"]
+    }
+
+    # Grant access to MinIO keys associated with this profile
+    {{- range .MinioInstances }}
+    path "{{ . }}/keys/{{ $.ProfileName }}" {
+    	capabilities = ["read"]
+    }
+    {{- end }}
+  protected-b.hcl: |
+    #
+    # Protected B policy for Kubeflow profile: {{ .ProfileName }}
+    # (policy managed by the custom Kubeflow Profiles controller)
+    #
+
+    # Grant full access to the KV created for this profile
+    path "kv_{{ .ProfileName }}/*" {
+    	capabilities = ["create", "update", "delete", "read", "list"]
+    }
+
+    # Grant access to the MinIO keys of the protected B instances only
+    {{- range .MinioInstances }}
+    {{- if eq . "minio_protected_b" }}
+    path "{{ . }}/keys/{{ $.ProfileName }}" {
+    	capabilities = ["read"]
+    }
+    {{- end }}
+    {{- end }}
+  read-only.hcl: |
+    #
+    # Read-only policy for Kubeflow profile: {{ .ProfileName }}
+    # (policy managed by the custom Kubeflow Profiles controller)
+    #
+
+    # Grant read access to the KV created for this profile
+    path "kv_{{ .ProfileName }}/*" {
+    	capabilities = ["read", "list"]
+    }
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: profile-configurator-minio
+  namespace: daaas
+data:
+  bucket-layouts.yaml: |
+    # The instances not listed get a bucket named after the profile
+    # and a folder of the profile in the shared bucket
+    minio_protected_b:
+      buckets:
+      - name: "{{ .ProfileName }}"
+        owned: true
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: profile-configurator-poddefaults
+  namespace: daaas
+  labels:
+    kubeflow-controller.statcan.gc.ca/poddefaults: "true"
+data:
+  minio-mounts.yaml: |
+    apiVersion: kubeflow.org/v1alpha1
+    kind: PodDefault
+    metadata:
+      name: minio-mounts
+    spec:
+      desc: Mount MinIO storage into the minio/ folder
+      selector:
+        matchLabels:
+          minio-mounts: "true"
+      annotations:
+        data.statcan.gc.ca/inject-boathouse: "true"
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	kubeflowv1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1"
+)
+
+// profileFinalizer is added to every Profile so the external state
+// created for it is cleaned up before the Profile is released.
+const profileFinalizer = "kubeflow-controller.statcan.gc.ca/finalizer"
+
+const (
+	// ErrFinalizeFailed is used as part of the Event 'reason' when the
+	// external state of a Profile could not be cleaned up
+	ErrFinalizeFailed = "ErrFinalizeFailed"
+
+	// MessageFinalizeFailed is the message used for Events when the
+	// external state of a Profile could not be cleaned up
+	MessageFinalizeFailed = "Failed to clean up %s: %v"
+)
+
+// hasFinalizer checks if the Profile carries the controller's finalizer.
+func hasFinalizer(profile *kubeflowv1.Profile) bool {
+	return StringArrayContains(profile.Finalizers, profileFinalizer)
+}
+
+// addFinalizer adds the controller's finalizer to the Profile if it is missing.
+func (c *Controller) addFinalizer(profile *kubeflowv1.Profile) (*kubeflowv1.Profile, error) {
+	if hasFinalizer(profile) {
+		return profile, nil
+	}
+
+	// NEVER modify objects from the store. It's a read-only, local cache.
+	profileCopy := profile.DeepCopy()
+	profileCopy.Finalizers = append(profileCopy.Finalizers, profileFinalizer)
+
+	klog.V(4).Infof("adding finalizer to profile %q", profile.Name)
+	return c.kubeflowclientset.KubeflowV1().Profiles().Update(context.TODO(), profileCopy, metav1.UpdateOptions{})
+}
+
+// finalizeProfile removes the secret store and MinIO state of a Profile being
+// deleted and then releases the Profile by removing the finalizer.
+// Kubernetes resources are owned by the Profile and are garbage collected.
+func (c *Controller) finalizeProfile(profile *kubeflowv1.Profile) error {
+	if !hasFinalizer(profile) {
+		return nil
+	}
+
+	klog.Infof("finalizing profile %q", profile.Name)
+
+	if err := c.secretStore.DeprovisionProfile(profile.Name, c.purgeDeletedProfiles); err != nil {
+		c.recorder.Event(profile, v1.EventTypeWarning, ErrFinalizeFailed, fmt.Sprintf(MessageFinalizeFailed, "secret store", err))
+		return err
+	}
+
+	if err := c.objectStorage.DeleteBucketsForProfile(MinIOProfile{
+		Name:  profile.Name,
+		Owner: profile.Spec.Owner.Name,
+	}, c.purgeDeletedProfiles); err != nil {
+		c.recorder.Event(profile, v1.EventTypeWarning, ErrFinalizeFailed, fmt.Sprintf(MessageFinalizeFailed, "object storage", err))
+
+		// Retrying doesn't help until the locked buckets are removed by
+		// an administrator, the Profile is finalized on a later resync
+		if errors.Is(err, ErrMinIOBucketLocked) {
+			klog.Warningf("profile %q waits for its locked buckets to be removed: %v", profile.Name, err)
+			return nil
+		}
+
+		return err
+	}
+
+	profileCopy := profile.DeepCopy()
+	finalizers := make([]string, 0, len(profileCopy.Finalizers))
+	for _, finalizer := range profileCopy.Finalizers {
+		if finalizer != profileFinalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	profileCopy.Finalizers = finalizers
+
+	_, err := c.kubeflowclientset.KubeflowV1().Profiles().Update(context.TODO(), profileCopy, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("finalized profile %q", profile.Name)
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+
+	kubeflowv1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1"
+	"github.com/StatCan/kubeflow-controller/pkg/generated/clientset/versioned/fake"
+)
+
+type fakeSecretStore struct {
+	deprovisioned map[string]bool
+	err           error
+}
+
+func (s *fakeSecretStore) ProvisionProfile(profile SecretStoreProfile) error {
+	return nil
+}
+
+func (s *fakeSecretStore) DeprovisionProfile(name string, purge bool) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.deprovisioned[name] = purge
+	return nil
+}
+
+func (s *fakeSecretStore) SweepSubjects(subjects map[string][]string, grace time.Duration) error {
+	return nil
+}
+
+type fakeObjectStorage struct {
+	deleted map[string]bool
+	err     error
+}
+
+func (s *fakeObjectStorage) CreateBucketsForProfile(profile MinIOProfile) MinIOResults {
+	return nil
+}
+
+func (s *fakeObjectStorage) DeleteBucketsForProfile(profile MinIOProfile, purge bool) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.deleted[profile.Name] = purge
+	return nil
+}
+
+// Tests that the finalizer is only removed once the secret store
+// and the object storage of the Profile have been cleaned up
+func TestFinalizeProfile(t *testing.T) {
+	tests := map[string]struct {
+		secretStoreErr   error
+		objectStorageErr error
+		expectErr        bool
+		expectReleased   bool
+		expectEvent      bool
+	}{
+		"success":             {expectReleased: true},
+		"secret store failed": {secretStoreErr: errors.New("vault sealed"), expectErr: true, expectEvent: true},
+		"bucket removal failed": {
+			objectStorageErr: errors.New("connection refused"),
+			expectErr:        true,
+			expectEvent:      true,
+		},
+		// Waits for a resync instead of retrying
+		"bucket locked": {
+			objectStorageErr: fmt.Errorf("bucket %q in instance %q: %w", "test", "minio_standard", ErrMinIOBucketLocked),
+			expectEvent:      true,
+		},
+	}
+
+	for name, test := range tests {
+		for _, purge := range []bool{false, true} {
+			profile := newTestProfile()
+			profile.Finalizers = []string{"other", profileFinalizer}
+
+			kubeflowclient := fake.NewSimpleClientset(profile)
+			recorder := record.NewFakeRecorder(10)
+			secretStore := &fakeSecretStore{deprovisioned: map[string]bool{}, err: test.secretStoreErr}
+			objectStorage := &fakeObjectStorage{deleted: map[string]bool{}, err: test.objectStorageErr}
+
+			c := &Controller{
+				kubeflowclientset:    kubeflowclient,
+				recorder:             recorder,
+				secretStore:          secretStore,
+				objectStorage:        objectStorage,
+				purgeDeletedProfiles: purge,
+			}
+
+			err := c.finalizeProfile(profile)
+			if test.expectErr && err == nil {
+				t.Errorf("%s: Expected an error", name)
+			} else if !test.expectErr && err != nil {
+				t.Errorf("%s: Unexpected error %v", name, err)
+			}
+
+			if test.secretStoreErr == nil {
+				if deleted, ok := objectStorage.deleted[profile.Name]; test.objectStorageErr == nil && (!ok || deleted != purge) {
+					t.Errorf("%s: Expected the buckets to be deleted with purge %t, got %v", name, purge, objectStorage.deleted)
+				}
+				if deprovisioned, ok := secretStore.deprovisioned[profile.Name]; !ok || deprovisioned != purge {
+					t.Errorf("%s: Expected the secret store to be deprovisioned with purge %t, got %v", name, purge, secretStore.deprovisioned)
+				}
+			} else if len(objectStorage.deleted) != 0 {
+				t.Errorf("%s: Expected the buckets to be kept, got %v", name, objectStorage.deleted)
+			}
+
+			var updated *kubeflowv1.Profile
+			for _, action := range kubeflowclient.Actions() {
+				if update, ok := action.(k8stesting.UpdateAction); ok {
+					updated = update.GetObject().(*kubeflowv1.Profile)
+				}
+			}
+
+			if test.expectReleased {
+				if updated == nil || hasFinalizer(updated) || !StringArrayContains(updated.Finalizers, "other") {
+					t.Errorf("%s: Expected only the finalizer of the controller to be removed, got %v", name, updated)
+				}
+			} else if updated != nil {
+				t.Errorf("%s: Expected the Profile to keep its finalizer, got %v", name, updated.Finalizers)
+			}
+
+			if events := len(recorder.Events); test.expectEvent && events != 1 {
+				t.Errorf("%s: Expected a warning event, got %d events", name, events)
+			} else if !test.expectEvent && events != 0 {
+				t.Errorf("%s: Expected no events, got %d", name, events)
+			}
+		}
+	}
+}
+
+func TestFinalizeProfile_noFinalizer(t *testing.T) {
+	profile := newTestProfile()
+	kubeflowclient := fake.NewSimpleClientset(profile)
+	secretStore := &fakeSecretStore{deprovisioned: map[string]bool{}}
+	objectStorage := &fakeObjectStorage{deleted: map[string]bool{}}
+
+	c := &Controller{
+		kubeflowclientset: kubeflowclient,
+		recorder:          record.NewFakeRecorder(10),
+		secretStore:       secretStore,
+		objectStorage:     objectStorage,
+	}
+
+	if err := c.finalizeProfile(profile); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(kubeflowclient.Actions()) != 0 || len(secretStore.deprovisioned) != 0 || len(objectStorage.deleted) != 0 {
+		t.Error("Expected a released Profile to be left alone")
+	}
+}
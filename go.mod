@@ -7,9 +7,11 @@ go 1.13
 require (
 	github.com/go-ini/ini v1.62.0 // indirect
 	github.com/gogo/protobuf v1.3.1
+	github.com/hashicorp/hcl v1.0.0
 	github.com/hashicorp/vault/api v1.0.4
 	github.com/minio/minio-go v6.0.14+incompatible // indirect
 	github.com/minio/minio-go/v7 v7.0.5
+	github.com/prometheus/client_golang v1.7.0
 	istio.io/api v0.0.0-20201217173512-1f62aaeb5ee3
 	istio.io/client-go v1.8.1
 	k8s.io/api v0.0.0-20200403220253-fa879b399cd0
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// maxProcessingTime is how long a worker may spend on a single
+// Profile before it is considered stuck.
+const maxProcessingTime = 10 * time.Minute
+
+// healthCheck is a named check run by the health endpoints.
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+// checkWorkers fails when the workers have stopped or one of them is stuck.
+// Replicas that haven't started their workers, such as replicas that aren't
+// the leader, are considered alive.
+func (c *Controller) checkWorkers() error {
+	if atomic.LoadInt32(&c.workersStarted) == 0 {
+		return nil
+	}
+
+	if atomic.LoadInt32(&c.workersRunning) == 0 {
+		return fmt.Errorf("no workers running")
+	}
+
+	var err error
+	c.processing.Range(func(key, value interface{}) bool {
+		if since := time.Since(value.(time.Time)); since > maxProcessingTime {
+			err = fmt.Errorf("worker processing %q for %s", key, since.Round(time.Second))
+			return false
+		}
+		return true
+	})
+
+	return err
+}
+
+// checkCachesSynced fails until every informer cache has synced.
+func (c *Controller) checkCachesSynced() error {
+	for _, synced := range c.cachesSynced() {
+		if !synced() {
+			return fmt.Errorf("informer caches not synced")
+		}
+	}
+
+	return nil
+}
+
+// healthHandler runs the checks, failing with 503 if any check fails.
+func healthHandler(checks []healthCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		failed := false
+
+		for _, check := range checks {
+			if err := check.check(); err != nil {
+				fmt.Fprintf(&body, "[-]%s failed: %v\n", check.name, err)
+				failed = true
+			} else {
+				fmt.Fprintf(&body, "[+]%s ok\n", check.name)
+			}
+		}
+
+		if failed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write(body.Bytes())
+	}
+}
+
+// serveHealth exposes the liveness checks on /healthz and
+// the readiness checks on /readyz.
+func serveHealth(addr string, liveness, readiness []healthCheck) {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", healthHandler(liveness))
+	mux.Handle("/readyz", healthHandler(readiness))
+
+	klog.Infof("serving health checks on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		klog.Fatalf("Error serving health checks: %s", err.Error())
+	}
+}
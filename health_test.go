@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthHandler(t *testing.T) {
+	tests := map[string]struct {
+		checks []healthCheck
+		status int
+		body   string
+	}{
+		"no checks": {
+			status: http.StatusOK,
+		},
+		"ok": {
+			checks: []healthCheck{{name: "workers", check: func() error { return nil }}},
+			status: http.StatusOK,
+			body:   "[+]workers ok\n",
+		},
+		"failed": {
+			checks: []healthCheck{
+				{name: "workers", check: func() error { return nil }},
+				{name: "caches", check: func() error { return errors.New("informer caches not synced") }},
+			},
+			status: http.StatusServiceUnavailable,
+			body:   "[+]workers ok\n[-]caches failed: informer caches not synced\n",
+		},
+	}
+
+	for name, test := range tests {
+		recorder := httptest.NewRecorder()
+		healthHandler(test.checks).ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+
+		if recorder.Code != test.status {
+			t.Errorf("%s: expected status %d, got %d", name, test.status, recorder.Code)
+		}
+
+		if recorder.Body.String() != test.body {
+			t.Errorf("%s: expected body %q, got %q", name, test.body, recorder.Body.String())
+		}
+	}
+}
+
+func TestCheckWorkers(t *testing.T) {
+	c := &Controller{}
+	if err := c.checkWorkers(); err != nil {
+		t.Errorf("Expected the workers which didn't start to be alive, got %v", err)
+	}
+
+	c.workersStarted = 1
+	if err := c.checkWorkers(); err == nil {
+		t.Error("Expected the stopped workers to fail")
+	}
+
+	c.workersRunning = 2
+	c.processing.Store("profile-a", time.Now())
+	if err := c.checkWorkers(); err != nil {
+		t.Errorf("Expected the running workers to be alive, got %v", err)
+	}
+
+	c.processing.Store("profile-b", time.Now().Add(-2*maxProcessingTime))
+	if err := c.checkWorkers(); err == nil || !strings.Contains(err.Error(), "profile-b") {
+		t.Errorf("Expected the stuck worker to fail, got %v", err)
+	}
+}
+
+func TestCheckCachesSynced(t *testing.T) {
+	synced := false
+	hasSynced := func() bool { return synced }
+	alwaysSynced := func() bool { return true }
+
+	c := &Controller{
+		podDefaultsSynced:         alwaysSynced,
+		secretsSynced:             alwaysSynced,
+		serviceAccountSynced:      alwaysSynced,
+		roleBindingSynced:         alwaysSynced,
+		profilesSynced:            hasSynced,
+		envoyFiltersSynced:        alwaysSynced,
+		profileIntegrationsSynced: alwaysSynced,
+		configMapsSynced:          alwaysSynced,
+	}
+
+	if err := c.checkCachesSynced(); err == nil {
+		t.Error("Expected the unsynced caches to fail")
+	}
+
+	synced = true
+	if err := c.checkCachesSynced(); err != nil {
+		t.Errorf("Expected the synced caches to pass, got %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog"
+
+	kubeflowv1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1"
+	kubeflowv1alpha1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1alpha1"
+)
+
+// IntegrationLabel is set on the resources created from a ProfileIntegration
+// to the name of the ProfileIntegration.
+const IntegrationLabel = "kubeflow-controller.statcan.gc.ca/integration"
+
+// doProfileIntegrations creates the RoleBindings declared by the
+// ProfileIntegrations for the Profile, and removes the ones that
+// are no longer declared.
+func (c *Controller) doProfileIntegrations(profile *kubeflowv1.Profile) error {
+	integrations, err := c.profileIntegrationsLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, 0)
+	desired := make(map[string]bool)
+	for _, integration := range integrations {
+		roleBindings, err := newIntegrationRoleBindings(profile, integration)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", integration.Name, err))
+			continue
+		}
+
+		for _, roleBinding := range roleBindings {
+			desired[roleBinding.Namespace+"/"+roleBinding.Name] = true
+
+			if err := c.doIntegrationRoleBinding(profile, roleBinding); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", integration.Name, err))
+			}
+		}
+	}
+
+	// Don't remove anything if the desired state is incomplete
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+
+	return c.removeStaleIntegrationRoleBindings(profile, desired)
+}
+
+// doIntegrationRoleBinding creates a RoleBinding of a ProfileIntegration,
+// or brings it back to its desired state.
+func (c *Controller) doIntegrationRoleBinding(profile *kubeflowv1.Profile, newRoleBinding *rbacv1.RoleBinding) error {
+	roleBinding, err := c.roleBindingLister.RoleBindings(newRoleBinding.Namespace).Get(newRoleBinding.Name)
+	// If the resource doesn't exist, we'll create it
+	if errors.IsNotFound(err) {
+		roleBinding, err = c.kubeclientset.RbacV1().RoleBindings(newRoleBinding.Namespace).Create(context.TODO(), newRoleBinding, metav1.CreateOptions{})
+	}
+
+	// If an error occurs during Get/Create, we'll requeue the item so we can
+	// attempt processing again later. This could have been caused by a
+	// temporary network failure, or any other transient reason.
+	if err != nil {
+		return err
+	}
+
+	// If the RoleBinding is not controlled by this Profile resource, we should log
+	// a warning to the event recorder and return error msg.
+	if !metav1.IsControlledBy(roleBinding, profile) {
+		msg := fmt.Sprintf(MessageResourceExists, roleBinding.Name)
+		c.recorder.Event(profile, v1.EventTypeWarning, ErrResourceExists, msg)
+		return fmt.Errorf(msg)
+	}
+
+	// The roleRef of a RoleBinding is immutable,
+	// so the RoleBinding is recreated when it changes.
+	if roleBinding.RoleRef != newRoleBinding.RoleRef {
+		msg := fmt.Sprintf(MessageResourceDrifted, "RoleBinding", roleBinding.Namespace+"/"+roleBinding.Name, "roleRef")
+		c.recorder.Event(profile, v1.EventTypeWarning, ResourceDrifted, msg)
+
+		err = c.kubeclientset.RbacV1().RoleBindings(roleBinding.Namespace).Delete(context.TODO(), roleBinding.Name, metav1.DeleteOptions{
+			Preconditions: metav1.NewUIDPreconditions(string(roleBinding.UID)),
+		})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+
+		_, err = c.kubeclientset.RbacV1().RoleBindings(newRoleBinding.Namespace).Create(context.TODO(), newRoleBinding, metav1.CreateOptions{})
+		return err
+	}
+
+	roleBindingCopy, drifted := updatedIntegrationRoleBinding(roleBinding, newRoleBinding)
+	if roleBindingCopy == nil {
+		return nil
+	}
+
+	if drifted {
+		msg := fmt.Sprintf(MessageResourceDrifted, "RoleBinding", roleBinding.Namespace+"/"+roleBinding.Name, "subjects")
+		c.recorder.Event(profile, v1.EventTypeWarning, ResourceDrifted, msg)
+	} else {
+		klog.Infof("labelling RoleBinding %s/%s of integration %q", roleBinding.Namespace, roleBinding.Name, newRoleBinding.Labels[IntegrationLabel])
+	}
+
+	_, err = c.kubeclientset.RbacV1().RoleBindings(roleBinding.Namespace).Update(context.TODO(), roleBindingCopy, metav1.UpdateOptions{})
+	return err
+}
+
+// updatedIntegrationRoleBinding returns a copy of the RoleBinding with the
+// subjects and label of its desired state, or nil when it's already in it.
+// drifted is only set when the subjects changed: the RoleBindings created
+// before they were labelled are adopted without being reported.
+func updatedIntegrationRoleBinding(roleBinding, newRoleBinding *rbacv1.RoleBinding) (*rbacv1.RoleBinding, bool) {
+	// The API server drops empty subjects
+	drifted := (len(roleBinding.Subjects) > 0 || len(newRoleBinding.Subjects) > 0) && !reflect.DeepEqual(roleBinding.Subjects, newRoleBinding.Subjects)
+	if !drifted && roleBinding.Labels[IntegrationLabel] == newRoleBinding.Labels[IntegrationLabel] {
+		return nil, false
+	}
+
+	roleBindingCopy := roleBinding.DeepCopy()
+	roleBindingCopy.Subjects = newRoleBinding.Subjects
+	if roleBindingCopy.Labels == nil {
+		roleBindingCopy.Labels = make(map[string]string)
+	}
+	roleBindingCopy.Labels[IntegrationLabel] = newRoleBinding.Labels[IntegrationLabel]
+
+	return roleBindingCopy, drifted
+}
+
+// removeStaleIntegrationRoleBindings deletes the RoleBindings created
+// from a ProfileIntegration which are no longer desired, either because
+// the ProfileIntegration was removed or because its template changed.
+func (c *Controller) removeStaleIntegrationRoleBindings(profile *kubeflowv1.Profile, desired map[string]bool) error {
+	requirement, err := labels.NewRequirement(IntegrationLabel, selection.Exists, nil)
+	if err != nil {
+		return err
+	}
+
+	roleBindings, err := c.roleBindingLister.List(labels.NewSelector().Add(*requirement))
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, 0)
+	for _, roleBinding := range roleBindings {
+		if !metav1.IsControlledBy(roleBinding, profile) || desired[roleBinding.Namespace+"/"+roleBinding.Name] {
+			continue
+		}
+
+		klog.Infof("removing RoleBinding %s/%s of integration %q from profile %q", roleBinding.Namespace, roleBinding.Name, roleBinding.Labels[IntegrationLabel], profile.Name)
+		err := c.kubeclientset.RbacV1().RoleBindings(roleBinding.Namespace).Delete(context.TODO(), roleBinding.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// newIntegrationRoleBindings renders the RoleBindings of the
+// ProfileIntegration for the Profile.
+func newIntegrationRoleBindings(profile *kubeflowv1.Profile, integration *kubeflowv1alpha1.ProfileIntegration) ([]*rbacv1.RoleBinding, error) {
+	data := newProfileTemplateData(profile)
+
+	roleBindings := make([]*rbacv1.RoleBinding, 0, len(integration.Spec.RoleBindings))
+	for _, spec := range integration.Spec.RoleBindings {
+		name, err := renderProfileTemplate(spec.Name, data)
+		if err != nil {
+			return nil, err
+		}
+
+		namespace, err := renderProfileTemplate(spec.Namespace, data)
+		if err != nil {
+			return nil, err
+		}
+
+		if namespace == "" {
+			namespace = profile.Name
+		}
+
+		subjects := make([]rbacv1.Subject, 0, len(spec.Subjects))
+		for _, subjectSpec := range spec.Subjects {
+			if subjectSpec.Owner {
+				subjects = append(subjects, defaultSubject(profile.Spec.Owner, profile.Name))
+				continue
+			}
+
+			subject := rbacv1.Subject{
+				Kind:     subjectSpec.Kind,
+				APIGroup: subjectSpec.APIGroup,
+			}
+
+			if subject.Name, err = renderProfileTemplate(subjectSpec.Name, data); err != nil {
+				return nil, err
+			}
+
+			if subject.Namespace, err = renderProfileTemplate(subjectSpec.Namespace, data); err != nil {
+				return nil, err
+			}
+
+			subjects = append(subjects, defaultSubject(subject, profile.Name))
+		}
+
+		roleBindings = append(roleBindings, &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					IntegrationLabel: integration.Name,
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(profile, kubeflowv1.SchemeGroupVersion.WithKind("Profile")),
+				},
+			},
+			RoleRef:  spec.RoleRef,
+			Subjects: subjects,
+		})
+	}
+
+	return roleBindings, nil
+}
+
+// defaultSubject fills in the defaults of the API server, so the RoleBinding
+// isn't seen as drifted once it's stored. The ServiceAccounts without a
+// namespace are the ones of the namespace of the Profile.
+func defaultSubject(subject rbacv1.Subject, namespace string) rbacv1.Subject {
+	if subject.Kind == rbacv1.ServiceAccountKind && subject.Namespace == "" {
+		subject.Namespace = namespace
+	}
+
+	if (subject.Kind == rbacv1.UserKind || subject.Kind == rbacv1.GroupKind) && subject.APIGroup == "" {
+		subject.APIGroup = rbacv1.GroupName
+	}
+
+	return subject
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	rbacv1listers "k8s.io/client-go/listers/rbac/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	kubeflowv1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1"
+	kubeflowv1alpha1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1alpha1"
+)
+
+func newTestProfile() *kubeflowv1.Profile {
+	return &kubeflowv1.Profile{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			UID:  "profile-uid",
+		},
+		Spec: kubeflowv1.ProfileSpec{
+			// Like the Profiles of Kubeflow, the owner has no apiGroup
+			Owner: rbacv1.Subject{
+				Kind: rbacv1.UserKind,
+				Name: "owner@example.com",
+			},
+		},
+	}
+}
+
+// Tests that the names, namespaces and subjects of the
+// RoleBindings are rendered for the Profile
+func TestNewIntegrationRoleBindings(t *testing.T) {
+	profile := newTestProfile()
+	roleRef := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "pachyderm-edit"}
+
+	integration := &kubeflowv1alpha1.ProfileIntegration{
+		ObjectMeta: metav1.ObjectMeta{Name: "pachyderm"},
+		Spec: kubeflowv1alpha1.ProfileIntegrationSpec{
+			RoleBindings: []kubeflowv1alpha1.ProfileIntegrationRoleBinding{
+				{
+					Name:    "pachyderm-{{ .ProfileName }}",
+					RoleRef: roleRef,
+					Subjects: []kubeflowv1alpha1.ProfileIntegrationSubject{
+						{Owner: true},
+						{Kind: rbacv1.ServiceAccountKind, Name: "default-editor"},
+						{Kind: rbacv1.UserKind, Name: "{{ .Owner }}"},
+					},
+				},
+				{
+					Name:      "{{ .ProfileName }}-editor",
+					Namespace: "pachyderm",
+					RoleRef:   roleRef,
+					Subjects: []kubeflowv1alpha1.ProfileIntegrationSubject{
+						{Kind: rbacv1.ServiceAccountKind, Name: "pachd", Namespace: "pachyderm"},
+					},
+				},
+			},
+		},
+	}
+
+	roleBindings, err := newIntegrationRoleBindings(profile, integration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(roleBindings) != 2 {
+		t.Fatalf("Expected 2 RoleBindings, got %d", len(roleBindings))
+	}
+
+	if roleBindings[0].Namespace != "test" || roleBindings[0].Name != "pachyderm-test" {
+		t.Errorf("Expected test/pachyderm-test, got %s/%s", roleBindings[0].Namespace, roleBindings[0].Name)
+	}
+
+	expectedSubjects := []rbacv1.Subject{
+		{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "owner@example.com"},
+		{Kind: rbacv1.ServiceAccountKind, Name: "default-editor", Namespace: "test"},
+		{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "owner@example.com"},
+	}
+	if !reflect.DeepEqual(roleBindings[0].Subjects, expectedSubjects) {
+		t.Errorf("Unexpected subjects %v", roleBindings[0].Subjects)
+	}
+
+	if roleBindings[1].Namespace != "pachyderm" || roleBindings[1].Name != "test-editor" {
+		t.Errorf("Expected pachyderm/test-editor, got %s/%s", roleBindings[1].Namespace, roleBindings[1].Name)
+	}
+
+	if roleBindings[1].Subjects[0].Namespace != "pachyderm" {
+		t.Errorf("Expected the namespace of the ServiceAccount to be kept, got %q", roleBindings[1].Subjects[0].Namespace)
+	}
+
+	for _, roleBinding := range roleBindings {
+		if roleBinding.Labels[IntegrationLabel] != "pachyderm" {
+			t.Errorf("Expected the integration label, got %v", roleBinding.Labels)
+		}
+
+		if !metav1.IsControlledBy(roleBinding, profile) {
+			t.Errorf("Expected %s/%s to be controlled by the Profile", roleBinding.Namespace, roleBinding.Name)
+		}
+
+		if roleBinding.RoleRef != roleRef {
+			t.Errorf("Unexpected roleRef %v", roleBinding.RoleRef)
+		}
+	}
+}
+
+func TestNewIntegrationRoleBindings_invalidTemplate(t *testing.T) {
+	integration := &kubeflowv1alpha1.ProfileIntegration{
+		ObjectMeta: metav1.ObjectMeta{Name: "pachyderm"},
+		Spec: kubeflowv1alpha1.ProfileIntegrationSpec{
+			RoleBindings: []kubeflowv1alpha1.ProfileIntegrationRoleBinding{
+				{Name: "{{ .Unknown }}"},
+			},
+		},
+	}
+
+	if _, err := newIntegrationRoleBindings(newTestProfile(), integration); err == nil {
+		t.Error("Expected an error for an unknown template field")
+	}
+}
+
+// Tests that only a change of the subjects is reported as a drift,
+// and that the RoleBindings created before they were labelled are adopted
+func TestUpdatedIntegrationRoleBinding(t *testing.T) {
+	desired := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pachyderm-test",
+			Namespace: "test",
+			Labels:    map[string]string{IntegrationLabel: "pachyderm"},
+		},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: "default-editor", Namespace: "test"},
+		},
+	}
+
+	tests := map[string]struct {
+		current *rbacv1.RoleBinding
+		updated bool
+		drifted bool
+	}{
+		"unchanged": {
+			current: desired.DeepCopy(),
+		},
+		"subjects": {
+			current: &rbacv1.RoleBinding{
+				ObjectMeta: desired.ObjectMeta,
+				Subjects: []rbacv1.Subject{
+					{Kind: rbacv1.ServiceAccountKind, Name: "intruder", Namespace: "test"},
+				},
+			},
+			updated: true,
+			drifted: true,
+		},
+		"unlabelled": {
+			current: &rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "pachyderm-test",
+					Namespace: "test",
+					Labels:    map[string]string{"app": "pachyderm"},
+				},
+				Subjects: desired.Subjects,
+			},
+			updated: true,
+		},
+	}
+
+	for name, test := range tests {
+		updated, drifted := updatedIntegrationRoleBinding(test.current, desired)
+		if (updated != nil) != test.updated || drifted != test.drifted {
+			t.Errorf("%s: expected updated=%v drifted=%v, got %v and %v", name, test.updated, test.drifted, updated != nil, drifted)
+			continue
+		}
+
+		if updated == nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(updated.Subjects, desired.Subjects) || updated.Labels[IntegrationLabel] != "pachyderm" {
+			t.Errorf("%s: expected the desired subjects and label, got %v", name, updated)
+		}
+
+		if name == "unlabelled" && updated.Labels["app"] != "pachyderm" {
+			t.Errorf("%s: expected the other labels to be kept, got %v", name, updated.Labels)
+		}
+
+		if updated == test.current {
+			t.Errorf("%s: expected a copy of the RoleBinding", name)
+		}
+	}
+
+	// The API server drops empty subjects
+	empty := &rbacv1.RoleBinding{ObjectMeta: desired.ObjectMeta, Subjects: []rbacv1.Subject{}}
+	if updated, _ := updatedIntegrationRoleBinding(&rbacv1.RoleBinding{ObjectMeta: desired.ObjectMeta}, empty); updated != nil {
+		t.Error("Expected no subjects to match empty subjects")
+	}
+}
+
+// Tests that a RoleBinding bound to the owner of the Profile is left alone
+// once it's stored with the defaults of the API server
+func TestDoIntegrationRoleBinding_owner(t *testing.T) {
+	profile := newTestProfile()
+	integration := &kubeflowv1alpha1.ProfileIntegration{
+		ObjectMeta: metav1.ObjectMeta{Name: "seldon"},
+		Spec: kubeflowv1alpha1.ProfileIntegrationSpec{
+			RoleBindings: []kubeflowv1alpha1.ProfileIntegrationRoleBinding{
+				{
+					Name:     "seldon-owner",
+					RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "seldon-manager"},
+					Subjects: []kubeflowv1alpha1.ProfileIntegrationSubject{{Owner: true}},
+				},
+			},
+		},
+	}
+
+	kubeclient := fake.NewSimpleClientset()
+	// Default the subjects like the API server
+	kubeclient.PrependReactor("create", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		roleBinding := action.(k8stesting.CreateAction).GetObject().(*rbacv1.RoleBinding)
+		for i := range roleBinding.Subjects {
+			if roleBinding.Subjects[i].Kind == rbacv1.UserKind && roleBinding.Subjects[i].APIGroup == "" {
+				roleBinding.Subjects[i].APIGroup = rbacv1.GroupName
+			}
+		}
+		return false, nil, nil
+	})
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	recorder := record.NewFakeRecorder(10)
+	c := &Controller{
+		kubeclientset:     kubeclient,
+		roleBindingLister: rbacv1listers.NewRoleBindingLister(indexer),
+		recorder:          recorder,
+	}
+
+	for i := 0; i < 2; i++ {
+		roleBindings, err := newIntegrationRoleBindings(profile, integration)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		kubeclient.ClearActions()
+		if err := c.doIntegrationRoleBinding(profile, roleBindings[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		stored, err := kubeclient.RbacV1().RoleBindings("test").Get(context.TODO(), "seldon-owner", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexer.Add(stored)
+	}
+
+	for _, action := range kubeclient.Actions() {
+		if action.GetVerb() != "get" {
+			t.Errorf("Expected no write on the second sync, got %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+
+	select {
+	case event := <-recorder.Events:
+		t.Errorf("Expected no event, got %q", event)
+	default:
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog"
+)
+
+// serviceAccountNamespaceFile holds the namespace of the
+// ServiceAccount of a pod running in the cluster.
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// podNamespace returns the namespace of the controller: the POD_NAMESPACE
+// environment variable, or the namespace of its ServiceAccount read from
+// namespaceFile. It's empty when the controller runs outside of a cluster.
+func podNamespace(namespaceFile string) string {
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+
+	data, err := ioutil.ReadFile(namespaceFile)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// LeaderElectionConfig configures the Lease used to elect the replica
+// running the workers.
+type LeaderElectionConfig struct {
+	LeaseName      string
+	LeaseNamespace string
+	LeaseDuration  time.Duration
+	RenewDeadline  time.Duration
+	RetryPeriod    time.Duration
+}
+
+// runWithLeaderElection blocks until stopCh is closed, calling run once this
+// replica holds the Lease. The Lease is released on shutdown so another
+// replica can take over without waiting for it to expire.
+func runWithLeaderElection(kubeclientset kubernetes.Interface, config LeaderElectionConfig, stopCh <-chan struct{}, run func(stopCh <-chan struct{})) {
+	identity, err := os.Hostname()
+	if err != nil {
+		klog.Fatalf("Error getting hostname for leader election: %s", err.Error())
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      config.LeaseName,
+			Namespace: config.LeaseNamespace,
+		},
+		Client: kubeclientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   config.LeaseDuration,
+		RenewDeadline:   config.RenewDeadline,
+		RetryPeriod:     config.RetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.Infof("%s acquired lease %s/%s", identity, config.LeaseNamespace, config.LeaseName)
+				run(ctx.Done())
+			},
+			OnStoppedLeading: func() {
+				select {
+				case <-stopCh:
+					klog.Infof("%s released lease %s/%s", identity, config.LeaseNamespace, config.LeaseName)
+				default:
+					// The workers may still be running, exit so the
+					// new leader is the only one reconciling.
+					klog.Fatalf("%s lost lease %s/%s", identity, config.LeaseNamespace, config.LeaseName)
+				}
+			},
+			OnNewLeader: func(current string) {
+				if current != identity {
+					klog.Infof("%s is the leader", current)
+				}
+			},
+		},
+	})
+}
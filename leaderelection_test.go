@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPodNamespace(t *testing.T) {
+	file, err := ioutil.TempFile("", "namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	file.WriteString("daaas\n")
+	file.Close()
+
+	defer os.Setenv("POD_NAMESPACE", os.Getenv("POD_NAMESPACE"))
+
+	os.Setenv("POD_NAMESPACE", "kubeflow")
+	if namespace := podNamespace(file.Name()); namespace != "kubeflow" {
+		t.Errorf("Expected the POD_NAMESPACE to take precedence, got %q", namespace)
+	}
+
+	os.Setenv("POD_NAMESPACE", "")
+	if namespace := podNamespace(file.Name()); namespace != "daaas" {
+		t.Errorf("Expected the namespace of the ServiceAccount, got %q", namespace)
+	}
+
+	if namespace := podNamespace("/nonexistent/namespace"); namespace != "" {
+		t.Errorf("Expected no namespace outside of a cluster, got %q", namespace)
+	}
+}
+
+// Tests that the workers are started once the Lease is acquired,
+// and that the Lease is released on shutdown
+func TestRunWithLeaderElection(t *testing.T) {
+	kubeclient := fake.NewSimpleClientset()
+	config := LeaderElectionConfig{
+		LeaseName:      "kubeflow-controller",
+		LeaseNamespace: "daaas",
+		LeaseDuration:  time.Second,
+		RenewDeadline:  500 * time.Millisecond,
+		RetryPeriod:    100 * time.Millisecond,
+	}
+
+	stopCh := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		runWithLeaderElection(kubeclient, config, stopCh, func(stopCh <-chan struct{}) {
+			close(started)
+			<-stopCh
+		})
+		close(done)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the workers to be started")
+	}
+
+	hostname, _ := os.Hostname()
+	lease, err := kubeclient.CoordinationV1().Leases("daaas").Get(context.TODO(), "kubeflow-controller", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != hostname {
+		t.Errorf("Expected the Lease to be held by %q, got %v", hostname, lease.Spec.HolderIdentity)
+	}
+
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the leader election to stop")
+	}
+
+	lease, err = kubeclient.CoordinationV1().Leases("daaas").Get(context.TODO(), "kubeflow-controller", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != "" {
+		t.Errorf("Expected the Lease to be released, got %q", *lease.Spec.HolderIdentity)
+	}
+}
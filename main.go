@@ -19,10 +19,12 @@ package main
 import (
 	"flag"
 	"os"
-	"strings"
 	"time"
 
 	vault "github.com/hashicorp/vault/api"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog"
@@ -42,10 +44,50 @@ var (
 	masterURL  string
 	kubeconfig string
 
-	imagePullSecret    string
-	minioInstances     string
-	kubernetesAuthPath string
-	oidcAuthAccessor   string
+	imagePullSecret                string
+	imagePullSecretSource          string
+	registryCredentials            string
+	defaultRegistryCredentials     string
+	imagePullSecretServiceAccounts string
+	minioInstances                 string
+	kubernetesAuthPath             string
+	oidcAuthAccessor               string
+
+	purgeDeletedProfiles bool
+
+	podDefaultTemplatesNamespace string
+	podDefaultsGCDryRun          bool
+
+	metricsAddr string
+	healthAddr  string
+
+	leaderElect    bool
+	leaderElection LeaderElectionConfig
+
+	vaultAuth VaultAuthConfig
+
+	vaultNamespace        string
+	vaultProfileNamespace string
+
+	vaultPolicyTemplatesDir string
+	vaultDefaultPolicyTier  string
+
+	vaultEntitySweep VaultEntitySweepConfig
+
+	vaultRoleServiceAccounts string
+	vaultRolePolicies        string
+	vaultRole                KubernetesRoleConfig
+
+	secretStoreBackend string
+
+	minioQuota          string
+	minioExpiration     string
+	minioLayouts        string
+	objectStorageConfig string
+	minioClientTTL      time.Duration
+	minioBreaker        MinIOCircuitBreakerConfig
+	minioAdoptBuckets   bool
+	minioBuckets        MinIOBucketConfig
 )
 
 func main() {
@@ -57,10 +99,30 @@ func main() {
 		imagePullSecret = os.Getenv("IMAGE_PULL_SECRET")
 	}
 
+	if len(imagePullSecretSource) == 0 {
+		imagePullSecretSource = os.Getenv("IMAGE_PULL_SECRET_SOURCE")
+	}
+
+	if len(registryCredentials) == 0 {
+		registryCredentials = os.Getenv("REGISTRY_CREDENTIALS")
+	}
+
+	if len(defaultRegistryCredentials) == 0 {
+		defaultRegistryCredentials = os.Getenv("DEFAULT_REGISTRY_CREDENTIALS")
+	}
+
 	if len(minioInstances) == 0 {
 		minioInstances = os.Getenv("MINIO_INSTANCES")
 	}
 
+	if len(objectStorageConfig) == 0 {
+		objectStorageConfig = os.Getenv("OBJECT_STORAGE_INSTANCES")
+	}
+
+	if len(minioLayouts) == 0 {
+		minioLayouts = os.Getenv("MINIO_BUCKET_LAYOUTS")
+	}
+
 	if len(kubernetesAuthPath) == 0 {
 		kubernetesAuthPath = os.Getenv("KUBERNETES_AUTH_PATH")
 	}
@@ -69,6 +131,56 @@ func main() {
 		oidcAuthAccessor = os.Getenv("OIDC_AUTH_ACCESSOR")
 	}
 
+	if len(leaderElection.LeaseNamespace) == 0 {
+		leaderElection.LeaseNamespace = podNamespace(serviceAccountNamespaceFile)
+	}
+
+	if len(secretStoreBackend) == 0 {
+		secretStoreBackend = os.Getenv("SECRET_STORE")
+	}
+
+	if len(secretStoreBackend) == 0 {
+		secretStoreBackend = SecretStoreVault
+	}
+
+	if len(vaultAuth.Method) == 0 {
+		vaultAuth.Method = os.Getenv("VAULT_AUTH_METHOD")
+	}
+
+	if len(vaultAuth.Method) == 0 {
+		vaultAuth.Method = VaultAuthAgent
+	}
+
+	if len(vaultNamespace) == 0 {
+		vaultNamespace = os.Getenv("VAULT_NAMESPACE")
+	}
+
+	if len(vaultProfileNamespace) == 0 {
+		vaultProfileNamespace = vaultNamespace
+	}
+
+	if len(vaultPolicyTemplatesDir) == 0 {
+		vaultPolicyTemplatesDir = os.Getenv("VAULT_POLICY_TEMPLATES_DIR")
+	}
+
+	if len(vaultAuth.Role) == 0 {
+		vaultAuth.Role = os.Getenv("VAULT_AUTH_ROLE")
+	}
+
+	if len(vaultAuth.RoleID) == 0 {
+		vaultAuth.RoleID = os.Getenv("VAULT_APPROLE_ROLE_ID")
+	}
+
+	if len(podDefaultTemplatesNamespace) == 0 {
+		podDefaultTemplatesNamespace = podNamespace(serviceAccountNamespaceFile)
+	}
+
+	// Watching every namespace would let anyone able to create
+	// a ConfigMap inject PodDefaults into every profile.
+	if len(podDefaultTemplatesNamespace) == 0 {
+		klog.Fatal("PodDefault templates require -poddefault-templates-namespace or the POD_NAMESPACE environment variable")
+	}
+
 	// set up signals so we handle the first shutdown signal gracefully
 	stopCh := signals.SetupSignalHandler()
 
@@ -95,23 +207,136 @@ func main() {
 	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, time.Minute*15)
 	kubeflowInformerFactory := informers.NewSharedInformerFactory(kubeflowClient, time.Minute*15)
 	istioInformerFactory := istioinformers.NewSharedInformerFactory(istioClient, time.Minute*15)
+	podDefaultTemplatesInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, time.Minute*15,
+		kubeinformers.WithNamespace(podDefaultTemplatesNamespace),
+		kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = PodDefaultTemplatesLabel + "=true"
+		}))
 
-	// Vault
-	vc, err := vault.NewClient(&vault.Config{
-		AgentAddress: os.Getenv("VAULT_AGENT_ADDR"),
-	})
+	registryCredentialsArray, err := ParseRegistryCredentials(registryCredentials)
 	if err != nil {
-		klog.Fatalf("Error initializing Vault client: %s", err)
+		klog.Fatalf("Error parsing registry credentials: %s", err)
+	}
+
+	// The credential given by -image-pull-secret(-source) is
+	// kept under its historic name and selected by default.
+	defaultRegistryCredentialsArray := splitList(defaultRegistryCredentials)
+
+	if len(imagePullSecretSource) > 0 || len(imagePullSecret) > 0 {
+		registryCredentialsArray = append(registryCredentialsArray, RegistryCredential{
+			Name:             LegacyRegistryCredential,
+			Source:           imagePullSecretSource,
+			DockerConfigJSON: []byte(imagePullSecret),
+		})
+
+		if len(defaultRegistryCredentialsArray) == 0 {
+			defaultRegistryCredentialsArray = []string{LegacyRegistryCredential}
+		}
 	}
 
-	minioInstancesArray := strings.Split(minioInstances, ",")
+	objectStorageInstances := NewMinIOInstances(splitList(minioInstances))
+	if len(objectStorageConfig) > 0 {
+		if len(minioInstances) > 0 {
+			klog.Fatalf("Only one of -minio-instances and -object-storage-instances can be set")
+		}
+
+		if objectStorageInstances, err = LoadObjectStorageInstances(objectStorageConfig); err != nil {
+			klog.Fatalf("Error loading the object storage instances: %s", err)
+		}
+	}
+
+	// The instances whose credentials are read from Vault
+	// get a role of the Vault MinIO plugin for each profile
+	vaultMinIOMounts := VaultMinIOMounts(objectStorageInstances)
+
+	readiness := make([]healthCheck, 0)
+
+	var secretStore SecretStoreProvisioner
+	var vaultConfigurer VaultConfigurer
+	switch secretStoreBackend {
+	case SecretStoreVault:
+		vaultConfig := &vault.Config{
+			AgentAddress: os.Getenv("VAULT_AGENT_ADDR"),
+		}
+		if vaultAuth.Method != VaultAuthAgent {
+			// Talk to Vault directly, at the address given by VAULT_ADDR
+			vaultConfig = vault.DefaultConfig()
+		}
+
+		vc, err := vault.NewClient(vaultConfig)
+		if err != nil {
+			klog.Fatalf("Error initializing Vault client: %s", err)
+		}
+
+		if len(vaultNamespace) > 0 {
+			vc.SetNamespace(vaultNamespace)
+		}
+
+		if vaultAuth.Method != VaultAuthAgent {
+			vaultAuthenticator := NewVaultAuthenticator(vc, vaultAuth)
+			secret, err := vaultAuthenticator.Login()
+			if err != nil {
+				klog.Fatalf("Error logging in to Vault: %s", err)
+			}
+
+			go vaultAuthenticator.Run(secret, stopCh)
+		}
+
+		configurer := NewVaultConfigurer(vc,
+			kubernetesAuthPath,
+			oidcAuthAccessor,
+			vaultMinIOMounts,
+			vaultProfileNamespace)
+		configurer.PolicyTemplatesDir = vaultPolicyTemplatesDir
+		configurer.DefaultPolicyTier = vaultDefaultPolicyTier
 
-	vaultConfigurer := NewVaultConfigurer(vc,
-		kubernetesAuthPath,
-		oidcAuthAccessor,
-		minioInstancesArray)
+		vaultRole.ServiceAccounts = splitList(vaultRoleServiceAccounts)
+		vaultRole.Policies = splitList(vaultRolePolicies)
+		configurer.KubernetesRole = vaultRole
 
-	minio := NewMinIO(minioInstancesArray, vaultConfigurer)
+		vaultConfigurer = configurer
+		secretStore = NewVaultSecretStore(configurer)
+
+		readiness = append(readiness, healthCheck{"vault", func() error {
+			_, err := vc.Sys().Health()
+			return err
+		}})
+	case SecretStoreNoop:
+		if len(vaultMinIOMounts) > 0 {
+			klog.Fatalf("The object storage instances with Vault credentials need the %s secret store", SecretStoreVault)
+		}
+
+		secretStore = NewNoopSecretStore()
+	default:
+		klog.Fatalf("Unknown secret store %q", secretStoreBackend)
+	}
+
+	var minioLayoutsMap map[string]MinIOBucketLayout
+	if len(minioLayouts) > 0 {
+		if minioLayoutsMap, err = LoadMinIOBucketLayouts(minioLayouts); err != nil {
+			klog.Fatalf("Error loading the MinIO bucket layouts: %s", err)
+		}
+	}
+
+	objectStorage := NewMinIO(objectStorageInstances,
+		vaultConfigurer,
+		kubeInformerFactory.Core().V1().Secrets().Lister(),
+		minioLayoutsMap,
+		minioClientTTL,
+		minioBreaker,
+		minioAdoptBuckets)
+
+	if len(minioQuota) > 0 {
+		quota, err := resource.ParseQuantity(minioQuota)
+		if err != nil {
+			klog.Fatalf("Error parsing the MinIO quota: %s", err)
+		}
+		minioBuckets.Quota = quota.Value()
+	}
+
+	if minioBuckets.Expiration, err = ParseMinIOExpiration(minioExpiration); err != nil {
+		klog.Fatalf("Error parsing the MinIO expiration: %s", err)
+	}
 
 	controller := NewController(kubeClient,
 		kubeflowClient,
@@ -122,26 +347,105 @@ func main() {
 		kubeInformerFactory.Rbac().V1().RoleBindings(),
 		kubeflowInformerFactory.Kubeflow().V1().Profiles(),
 		istioInformerFactory.Networking().V1alpha3().EnvoyFilters(),
-		[]byte(imagePullSecret),
-		vaultConfigurer,
-		minio)
+		kubeflowInformerFactory.Kubeflow().V1alpha1().ProfileIntegrations(),
+		podDefaultTemplatesInformerFactory.Core().V1().ConfigMaps(),
+		registryCredentialsArray,
+		defaultRegistryCredentialsArray,
+		splitList(imagePullSecretServiceAccounts),
+		secretStore,
+		objectStorage,
+		minioBuckets,
+		purgeDeletedProfiles,
+		podDefaultsGCDryRun,
+		vaultEntitySweep)
+
+	prometheus.MustRegister(newProfileConditionsCollector(kubeflowInformerFactory.Kubeflow().V1().Profiles().Lister()))
+	go serveMetrics(metricsAddr)
+
+	go serveHealth(healthAddr,
+		[]healthCheck{
+			{"workers", controller.checkWorkers},
+		},
+		append([]healthCheck{
+			{"informers", controller.checkCachesSynced},
+		}, readiness...))
 
 	// notice that there is no need to run Start methods in a separate goroutine. (i.e. go kubeInformerFactory.Start(stopCh)
 	// Start method is non-blocking and runs all registered informers in a dedicated goroutine.
 	kubeInformerFactory.Start(stopCh)
 	kubeflowInformerFactory.Start(stopCh)
 	istioInformerFactory.Start(stopCh)
+	podDefaultTemplatesInformerFactory.Start(stopCh)
 
-	if err = controller.Run(2, stopCh); err != nil {
-		klog.Fatalf("Error running controller: %s", err.Error())
+	run := func(stopCh <-chan struct{}) {
+		if err := controller.Run(2, stopCh); err != nil {
+			klog.Fatalf("Error running controller: %s", err.Error())
+		}
 	}
+
+	if !leaderElect {
+		run(stopCh)
+		return
+	}
+
+	if len(leaderElection.LeaseNamespace) == 0 {
+		klog.Fatal("Leader election requires -leader-election-namespace or the POD_NAMESPACE environment variable outside of a cluster, or -leader-elect=false")
+	}
+
+	// Only the leader starts the workers
+	runWithLeaderElection(kubeClient, leaderElection, stopCh, run)
 }
 
 func init() {
 	flag.StringVar(&imagePullSecret, "image-pull-secret", "", "Encoded dockerconfigjson for the image pull secret. Ignored if empty.")
+	flag.StringVar(&imagePullSecretSource, "image-pull-secret-source", "", "Namespace/name of the Secret the image pull secret is copied from. Takes precedence over -image-pull-secret.")
+	flag.StringVar(&registryCredentials, "registry-credentials", "", "Comma-separated list of name=namespace/secret registry credentials, copied as image pull secrets into the profiles which select them.")
+	flag.StringVar(&defaultRegistryCredentials, "default-registry-credentials", "", "Comma-separated names of the registry credentials of profiles without the kubeflow-controller.statcan.gc.ca/registry-credentials annotation.")
+	flag.StringVar(&imagePullSecretServiceAccounts, "image-pull-secret-service-accounts", "default-editor", "Comma-separated ServiceAccounts of the profiles the image pull secrets are attached to.")
 	flag.StringVar(&minioInstances, "minio-instances", "", "MinIO instances to configure in Vault.")
 	flag.StringVar(&kubernetesAuthPath, "kubernetes-auth-path", "", "Kubernetes auth path the configure in Vault.")
 	flag.StringVar(&oidcAuthAccessor, "oidc-auth-accessor", "", "Mount accessor of the OIDC auth.")
+	flag.StringVar(&objectStorageConfig, "object-storage-instances", "", "File declaring the S3-compatible object storage instances, instead of -minio-instances. Defaults to the OBJECT_STORAGE_INSTANCES environment variable.")
+	flag.StringVar(&minioLayouts, "minio-bucket-layouts", "", "File holding the layouts of the buckets of the profiles, by MinIO instance. Defaults to the MINIO_BUCKET_LAYOUTS environment variable.")
+	flag.DurationVar(&minioClientTTL, "minio-client-ttl", 5*time.Minute, "Duration the configuration of a MinIO instance is read from Vault for, or less when its lease is shorter.")
+	flag.IntVar(&minioBreaker.Threshold, "minio-breaker-threshold", 3, "Number of consecutive failures to reach a MinIO instance after which it's skipped. 0 disables the circuit breaker.")
+	flag.DurationVar(&minioBreaker.Backoff, "minio-breaker-backoff", time.Minute, "Duration an unreachable MinIO instance is skipped for.")
+	flag.BoolVar(&minioAdoptBuckets, "minio-adopt-untagged-buckets", false, "Tag the untagged buckets of the profiles which hold objects with their profile, such as the buckets created before they were tagged. Only the empty ones are adopted by default.")
+	flag.StringVar(&minioQuota, "minio-quota", "", "Default hard quota of the bucket of a profile, such as 100Gi. No quota by default.")
+	flag.BoolVar(&minioBuckets.Versioning, "minio-versioning", false, "Enable the versioning of the bucket of a profile by default.")
+	flag.BoolVar(&minioBuckets.ObjectLock, "minio-object-lock", false, "Create the bucket of a profile with object lock by default.")
+	flag.StringVar(&minioExpiration, "minio-expiration", "", "Default expiration of the objects of the bucket of a profile, as a comma-separated list of prefix=days, such as tmp/=30.")
+	flag.BoolVar(&purgeDeletedProfiles, "purge-deleted-profiles", false, "Remove the Vault secrets and MinIO buckets of deleted profiles instead of archiving them.")
+	flag.StringVar(&podDefaultTemplatesNamespace, "poddefault-templates-namespace", "", "Namespace of the ConfigMaps holding PodDefault templates. Defaults to the POD_NAMESPACE environment variable, or the namespace of the ServiceAccount of the pod.")
+	flag.BoolVar(&podDefaultsGCDryRun, "poddefaults-gc-dry-run", true, "Only log the PodDefaults of profiles which are no longer registered, or whose template was removed, instead of deleting them.")
+	flag.StringVar(&metricsAddr, "metrics-addr", ":9090", "The address the Prometheus metrics endpoint binds to.")
+	flag.StringVar(&healthAddr, "health-addr", ":8081", "The address the /healthz and /readyz endpoints bind to.")
+	flag.BoolVar(&leaderElect, "leader-elect", true, "Elect a leader before starting the workers, so more than one replica can run.")
+	flag.StringVar(&leaderElection.LeaseName, "leader-election-lease-name", "kubeflow-controller", "Name of the Lease used for leader election.")
+	flag.StringVar(&leaderElection.LeaseNamespace, "leader-election-namespace", "", "Namespace of the Lease used for leader election. Defaults to the POD_NAMESPACE environment variable, or the namespace of the ServiceAccount of the pod.")
+	flag.DurationVar(&leaderElection.LeaseDuration, "leader-election-lease-duration", 15*time.Second, "Duration non-leader replicas wait before trying to acquire the Lease.")
+	flag.DurationVar(&leaderElection.RenewDeadline, "leader-election-renew-deadline", 10*time.Second, "Duration the leader retries renewing the Lease before giving it up.")
+	flag.DurationVar(&leaderElection.RetryPeriod, "leader-election-retry-period", 2*time.Second, "Duration between attempts to acquire or renew the Lease.")
+	flag.StringVar(&secretStoreBackend, "secret-store", "", "Backend of the secret stores of the profiles: vault or noop. Defaults to the SECRET_STORE environment variable, or vault.")
+	flag.StringVar(&vaultAuth.Method, "vault-auth-method", "", "How to authenticate to Vault: agent, kubernetes, approle or token-file. Defaults to the VAULT_AUTH_METHOD environment variable, or agent.")
+	flag.StringVar(&vaultAuth.MountPath, "vault-auth-mount-path", "", "Mount path of the Vault auth method. Defaults to the name of the method.")
+	flag.StringVar(&vaultAuth.Role, "vault-auth-role", "", "Role to log in to the Vault Kubernetes auth method with. Defaults to the VAULT_AUTH_ROLE environment variable.")
+	flag.StringVar(&vaultAuth.ServiceAccountTokenPath, "vault-auth-service-account-token-path", "/var/run/secrets/tokens/vault-token", "Path of the projected ServiceAccount token used by the Vault Kubernetes auth method.")
+	flag.StringVar(&vaultAuth.RoleID, "vault-approle-role-id", "", "Role ID used by the Vault AppRole auth method. Defaults to the VAULT_APPROLE_ROLE_ID environment variable.")
+	flag.StringVar(&vaultAuth.SecretIDPath, "vault-approle-secret-id-path", "/var/run/secrets/vault/secret-id", "Path of the secret ID used by the Vault AppRole auth method.")
+	flag.StringVar(&vaultAuth.TokenPath, "vault-token-path", "/var/run/secrets/vault/token", "Path of the token used by the token-file Vault auth method.")
+	flag.DurationVar(&vaultAuth.RetryPeriod, "vault-auth-retry-period", 10*time.Second, "Duration between failed attempts to log in to Vault.")
+	flag.StringVar(&vaultNamespace, "vault-namespace", "", "Vault Enterprise namespace of the requests. Defaults to the VAULT_NAMESPACE environment variable.")
+	flag.StringVar(&vaultProfileNamespace, "vault-profile-namespace", "", "Vault Enterprise namespace of the requests configuring a profile, rendered with {{ .ProfileName }}. Defaults to -vault-namespace.")
+	flag.StringVar(&vaultPolicyTemplatesDir, "vault-policy-templates-dir", "", "Directory of the Vault policy templates, one <tier>.hcl file per tier. Defaults to the VAULT_POLICY_TEMPLATES_DIR environment variable.")
+	flag.StringVar(&vaultDefaultPolicyTier, "vault-default-policy-tier", STANDARD_POLICY_TIER, "Vault policy tier of the profiles without the "+VaultPolicyTierLabel+" label or annotation.")
+	flag.StringVar(&vaultRoleServiceAccounts, "vault-role-service-accounts", "*", "Comma-separated ServiceAccounts of a profile namespace that can log in to Vault with the Kubernetes auth role of the profile.")
+	flag.DurationVar(&vaultRole.TokenTTL, "vault-role-token-ttl", 0, "TTL of the tokens issued by the Kubernetes auth roles of the profiles. 0 uses the default of Vault.")
+	flag.DurationVar(&vaultRole.TokenMaxTTL, "vault-role-token-max-ttl", 0, "Maximum TTL of the tokens issued by the Kubernetes auth roles of the profiles. 0 uses the default of Vault.")
+	flag.StringVar(&vaultRole.Audience, "vault-role-audience", "", "Audience the ServiceAccount tokens must have to log in with the Kubernetes auth roles of the profiles.")
+	flag.StringVar(&vaultRolePolicies, "vault-role-policies", "", "Comma-separated policies granted by the Kubernetes auth role of every profile, besides its own policy.")
+	flag.DurationVar(&vaultEntitySweep.Period, "vault-entity-sweep-period", 0, "Period between the sweeps of the Vault entities which no longer belong to a profile, such as 1h. 0, the default, disables the sweep.")
+	flag.DurationVar(&vaultEntitySweep.GracePeriod, "vault-entity-grace-period", 7*24*time.Hour, "Duration a Vault entity is kept for once it no longer belongs to a profile.")
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&masterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
 }
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	listers "github.com/StatCan/kubeflow-controller/pkg/generated/listers/kubeflowcontroller/v1"
+)
+
+const metricsNamespace = "kubeflow_controller"
+
+var (
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_duration_seconds",
+		Help:      "Duration of each reconcile step of a Profile.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"step"})
+
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_total",
+		Help:      "Number of reconcile steps of a Profile by outcome.",
+	}, []string{"step", "result"})
+
+	vaultRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "vault_request_duration_seconds",
+		Help:      "Latency of the requests made to Vault.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	vaultRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "vault_requests_total",
+		Help:      "Number of requests made to Vault by outcome.",
+	}, []string{"operation", "result"})
+
+	minioOperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "minio_bucket_operations_total",
+		Help:      "Number of bucket operations made to MinIO by outcome.",
+	}, []string{"instance", "operation", "result"})
+)
+
+// Workqueue metrics, labelled by the name of the queue.
+var (
+	workqueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "workqueue",
+		Name:      "depth",
+		Help:      "Current depth of workqueue.",
+	}, []string{"name"})
+
+	workqueueAdds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "workqueue",
+		Name:      "adds_total",
+		Help:      "Total number of adds handled by workqueue.",
+	}, []string{"name"})
+
+	workqueueLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: "workqueue",
+		Name:      "queue_duration_seconds",
+		Help:      "How long in seconds an item stays in workqueue before being requested.",
+		Buckets:   prometheus.ExponentialBuckets(10e-9, 10, 10),
+	}, []string{"name"})
+
+	workqueueWorkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: "workqueue",
+		Name:      "work_duration_seconds",
+		Help:      "How long in seconds processing an item from workqueue takes.",
+		Buckets:   prometheus.ExponentialBuckets(10e-9, 10, 10),
+	}, []string{"name"})
+
+	workqueueUnfinishedWork = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "workqueue",
+		Name:      "unfinished_work_seconds",
+		Help:      "How many seconds of work has been done that is in progress and hasn't been observed by work_duration.",
+	}, []string{"name"})
+
+	workqueueLongestRunningProcessor = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "workqueue",
+		Name:      "longest_running_processor_seconds",
+		Help:      "How many seconds has the longest running processor for workqueue been running.",
+	}, []string{"name"})
+
+	workqueueRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "workqueue",
+		Name:      "retries_total",
+		Help:      "Total number of retries handled by workqueue.",
+	}, []string{"name"})
+)
+
+// workqueueMetricsProvider exposes the workqueue metrics to Prometheus.
+type workqueueMetricsProvider struct{}
+
+func (workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return workqueueDepth.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return workqueueAdds.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return workqueueLatency.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return workqueueWorkDuration.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueUnfinishedWork.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueLongestRunningProcessor.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return workqueueRetries.WithLabelValues(name)
+}
+
+// profileConditionsCollector counts the Profiles in each condition state
+// from the informer cache every time the metrics are scraped.
+type profileConditionsCollector struct {
+	profilesLister listers.ProfileLister
+	desc           *prometheus.Desc
+}
+
+func newProfileConditionsCollector(profilesLister listers.ProfileLister) prometheus.Collector {
+	return &profileConditionsCollector{
+		profilesLister: profilesLister,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "profile_conditions"),
+			"Number of Profiles in each condition state.",
+			[]string{"type", "status"}, nil),
+	}
+}
+
+func (p *profileConditionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.desc
+}
+
+func (p *profileConditionsCollector) Collect(ch chan<- prometheus.Metric) {
+	profiles, err := p.profilesLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+
+	type conditionKey struct{ conditionType, status string }
+	counts := make(map[conditionKey]int)
+	for _, profile := range profiles {
+		for _, condition := range profile.Status.Conditions {
+			counts[conditionKey{condition.Type, condition.Status}]++
+		}
+	}
+
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(p.desc, prometheus.GaugeValue, float64(count), key.conditionType, key.status)
+	}
+}
+
+// result converts an error into the value of a result label.
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// observeReconcileStep records the duration and outcome of a reconcile step.
+func observeReconcileStep(step string, start time.Time, err error) {
+	reconcileDuration.WithLabelValues(step).Observe(time.Since(start).Seconds())
+	reconcileTotal.WithLabelValues(step, result(err)).Inc()
+}
+
+// observeVaultRequest records the latency and outcome of a Vault request.
+func observeVaultRequest(operation string, start time.Time, err error) {
+	vaultRequestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	vaultRequestsTotal.WithLabelValues(operation, result(err)).Inc()
+}
+
+// observeMinIOOperation records the outcome of a MinIO bucket operation.
+func observeMinIOOperation(instance, operation string, err error) {
+	minioOperationsTotal.WithLabelValues(instance, operation, result(err)).Inc()
+}
+
+// serveMetrics exposes the Prometheus metrics on /metrics.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	klog.Infof("serving metrics on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		klog.Fatalf("Error serving metrics: %s", err.Error())
+	}
+}
+
+func init() {
+	prometheus.MustRegister(
+		reconcileDuration,
+		reconcileTotal,
+		vaultRequestDuration,
+		vaultRequestsTotal,
+		minioOperationsTotal,
+		workqueueDepth,
+		workqueueAdds,
+		workqueueLatency,
+		workqueueWorkDuration,
+		workqueueUnfinishedWork,
+		workqueueLongestRunningProcessor,
+		workqueueRetries,
+	)
+
+	// The provider must be set before the workqueue is created.
+	workqueue.SetProvider(workqueueMetricsProvider{})
+}
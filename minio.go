@@ -3,67 +3,431 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	v1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/klog"
 )
 
-// NewMinIO creates a MinIO instance.
-func NewMinIO(minioInstances []string, vault VaultConfigurer) MinIO {
+const (
+	// MinIOQuotaAnnotation sets the hard quota of the bucket of a Profile, as a
+	// quantity such as 100Gi. A quota of 0 removes the quota.
+	MinIOQuotaAnnotation = "kubeflow-controller.statcan.gc.ca/minio-quota"
+	// MinIOVersioningAnnotation enables ("true") or suspends ("false")
+	// the versioning of the bucket of a Profile.
+	MinIOVersioningAnnotation = "kubeflow-controller.statcan.gc.ca/minio-versioning"
+	// MinIOObjectLockAnnotation enables the object lock of the bucket of a Profile.
+	// It only applies to the buckets created after it's set.
+	MinIOObjectLockAnnotation = "kubeflow-controller.statcan.gc.ca/minio-object-lock"
+	// MinIOExpirationAnnotation expires the objects of the bucket of a Profile
+	// as a comma-separated list of prefix=days, such as tmp/=30.
+	MinIOExpirationAnnotation = "kubeflow-controller.statcan.gc.ca/minio-expiration"
+
+	// MinIOProfileTag tags the owned buckets with the name of their Profile,
+	// so the bucket of a Profile is never configured or removed by another.
+	MinIOProfileTag = "kubeflow-controller.statcan.gc.ca/profile"
+
+	// SHARED_BUCKET is the bucket the profiles share their files in, under
+	// a prefix of their name, in the default layout of the buckets.
+	SHARED_BUCKET = "shared"
+)
+
+// ErrMinIOBucketLocked is returned when purging a bucket with object lock
+// enabled, whose objects can't be removed before their retention expires.
+var ErrMinIOBucketLocked = errors.New("object lock is enabled, the bucket has to be removed by an administrator")
+
+// NewMinIO creates an object storage provisioner for MinIO and other
+// S3-compatible instances. The credentials of the instances are read from
+// Vault or the Secrets, at most once every clientTTL.
+func NewMinIO(instances []ObjectStorageInstance, vault VaultConfigurer, secrets v1listers.SecretLister, layouts map[string]MinIOBucketLayout, clientTTL time.Duration, breaker MinIOCircuitBreakerConfig, adoptBuckets bool) ObjectStorageProvisioner {
+	credentials := &objectStorageCredentials{
+		vault:   vault,
+		secrets: secrets,
+	}
+
 	return &MinIOStruct{
-		MinioInstances:  minioInstances,
-		VaultConfigurer: vault,
+		Instances:    instances,
+		Layouts:      layouts,
+		AdoptBuckets: adoptBuckets,
+		clients:      newMinIOClientCache(credentials.configuration, clientTTL),
+		breaker:      newMinIOCircuitBreaker(breaker.Threshold, breaker.Backoff),
 	}
 }
 
-// MinIO is the interface for interacting with a MinIO instance.
-type MinIO interface {
-	CreateBucketsForProfile(profileName string) error
+// MinIOCircuitBreakerConfig configures the skipping of the unreachable MinIO instances
+type MinIOCircuitBreakerConfig struct {
+	// Threshold is the number of consecutive failures to reach an instance
+	// after which it's skipped, the breaker is disabled when it's zero.
+	Threshold int
+	// Backoff is the duration an unreachable instance is skipped for.
+	Backoff time.Duration
+}
+
+// MinIOResults are the outcomes of an operation in each MinIO instance,
+// a nil error meaning the operation succeeded in the instance.
+type MinIOResults map[string]error
+
+// Instances returns the names of the instances, sorted.
+func (r MinIOResults) Instances() []string {
+	instances := make([]string, 0, len(r))
+	for instance := range r {
+		instances = append(instances, instance)
+	}
+	sort.Strings(instances)
+
+	return instances
+}
+
+// Err aggregates the errors of the instances.
+func (r MinIOResults) Err() error {
+	errs := make([]error, 0)
+	for _, instance := range r.Instances() {
+		if err := r[instance]; err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", instance, err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
 }
 
-// MinIOStruct is a MinIO implementation.
+// MinIOProfile describes the profile to create the buckets for
+type MinIOProfile struct {
+	Name  string
+	Owner string
+	// Buckets configures the buckets owned by the profile.
+	Buckets MinIOBucketConfig
+}
+
+// MinIOBucketConfig configures the buckets owned by a profile
+type MinIOBucketConfig struct {
+	// Quota of the bucket in bytes, the bucket has no quota when it's zero.
+	Quota int64
+	// Versioning keeps the previous versions of the objects.
+	Versioning bool
+	// ObjectLock creates the bucket with object locking enabled,
+	// which implies versioning. It can't be changed afterwards.
+	ObjectLock bool
+	// Expiration is the number of days after which the objects
+	// under each prefix are removed.
+	Expiration map[string]int
+}
+
+// ParseMinIOExpiration parses a comma-separated list of prefix=days.
+func ParseMinIOExpiration(value string) (map[string]int, error) {
+	expiration := make(map[string]int)
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid expiration %q, expected prefix=days", entry)
+		}
+
+		days, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("invalid expiration %q, expected a positive number of days", entry)
+		}
+
+		expiration[strings.TrimSpace(parts[0])] = days
+	}
+
+	return expiration, nil
+}
+
+// MinIOStruct is an ObjectStorageProvisioner talking to the
+// instances with the MinIO client, which supports any S3 API.
 type MinIOStruct struct {
-	VaultConfigurer VaultConfigurer
-	MinioInstances  []string
+	Instances []ObjectStorageInstance
+	// Layouts of the buckets of the profiles by instance. The instances
+	// without a layout, of their own or here, get the DefaultMinIOBucketLayout.
+	Layouts map[string]MinIOBucketLayout
+	// AdoptBuckets tags the untagged owned buckets which hold objects with
+	// their profile, such as the buckets created before they were tagged.
+	AdoptBuckets bool
+
+	clients *minioClientCache
+	breaker *minioCircuitBreaker
+
+	// policyMutex serializes the updates of the policies of the shared
+	// buckets, which hold a statement for every profile.
+	policyMutex sync.Mutex
+}
+
+// newClient returns a client for the given instance, and a
+// client of its admin API.
+func (m *MinIOStruct) newClient(instance ObjectStorageInstance) (*minio.Client, *minioAdminClient, error) {
+	return m.clients.get(instance)
 }
 
-// CreateBucketsForProfile creates the profile's buckets in the MinIO instances.
-func (m *MinIOStruct) CreateBucketsForProfile(profileName string) error {
-	for _, instance := range m.MinioInstances {
-		conf, err := m.VaultConfigurer.GetMinIOConfiguration(instance)
+// forEachInstance runs the operation in every instance, independently of
+// each other, except the ones skipped by the circuit breaker.
+func (m *MinIOStruct) forEachInstance(operation func(instance ObjectStorageInstance) error) MinIOResults {
+	results := make(MinIOResults, len(m.Instances))
+	for _, instance := range m.Instances {
+		if err := m.breaker.allow(instance.Name); err != nil {
+			results[instance.Name] = err
+			continue
+		}
+
+		err := operation(instance)
+		m.breaker.record(instance.Name, err)
+
+		// Drop the clients when their credentials are refused,
+		// so they're read again on the next sync
+		if err != nil && isMinIOCredentialsError(err) {
+			klog.Infof("the credentials of object storage instance %q were refused, dropping its clients", instance.Name)
+			m.clients.invalidate(instance.Name)
+		}
+
+		results[instance.Name] = err
+	}
+
+	return results
+}
+
+// buckets returns the buckets of the profile in the instance.
+func (m *MinIOStruct) buckets(instance ObjectStorageInstance, profile MinIOProfile) ([]minioBucket, error) {
+	layout, ok := m.Layouts[instance.Name]
+	if instance.Layout != nil {
+		layout = *instance.Layout
+	} else if !ok {
+		layout = DefaultMinIOBucketLayout
+	}
+
+	return layout.render(profileTemplateData{
+		ProfileName: profile.Name,
+		Owner:       profile.Owner,
+	})
+}
+
+// CreateBucketsForProfile creates the profile's buckets in the MinIO instances,
+// and reconciles the configuration of the buckets of the profile and its
+// statements in the policies of the shared buckets.
+func (m *MinIOStruct) CreateBucketsForProfile(profile MinIOProfile) MinIOResults {
+	return m.forEachInstance(func(instance ObjectStorageInstance) error {
+		buckets, err := m.buckets(instance, profile)
 		if err != nil {
 			return err
 		}
 
-		client, err := minio.New(conf.Endpoint, &minio.Options{
-			Creds:  credentials.NewStaticV4(conf.AccessKeyID, conf.SecretAccessKey, ""),
-			Secure: conf.UseSSL,
+		client, admin, err := m.newClient(instance)
+		if err != nil {
+			return fmt.Errorf("reading the credentials: %v", err)
+		}
+
+		for _, bucket := range buckets {
+			if err := m.doBucket(client, admin, instance, profile, bucket); err != nil {
+				return fmt.Errorf("bucket %q: %w", bucket.name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// doBucket creates the bucket if it doesn't exist yet. The owned buckets get
+// the configuration of the profile, the prefixes of the shared buckets of
+// the MinIO instances configured in Vault get a statement in their policy.
+func (m *MinIOStruct) doBucket(client *minio.Client, admin *minioAdminClient, objectStorage ObjectStorageInstance, profile MinIOProfile, bucket minioBucket) error {
+	instance := objectStorage.Name
+	objectLock := bucket.owned && profile.Buckets.ObjectLock
+
+	exists, err := client.BucketExists(context.Background(), bucket.name)
+	observeMinIOOperation(instance, "BucketExists", err)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		klog.Infof("making bucket %q in instance %q", bucket.name, instance)
+		err = client.MakeBucket(context.Background(), bucket.name, minio.MakeBucketOptions{
+			ObjectLocking: objectLock,
 		})
+		observeMinIOOperation(instance, "MakeBucket", err)
 		if err != nil {
 			return err
 		}
+	} else {
+		klog.Infof("bucket %q in instance %q already exists", bucket.name, instance)
+	}
+
+	if bucket.owned {
+		if err := doBucketOwner(client, instance, bucket.name, profile.Name, m.AdoptBuckets); err != nil {
+			return err
+		}
+
+		if err := doBucketObjectLock(client, instance, bucket.name, objectLock); err != nil {
+			return err
+		}
 
-		for _, bucket := range []string{profileName, "shared"} {
-			exists, err := client.BucketExists(context.Background(), bucket)
+		// Object lock implies versioning
+		if !objectLock {
+			if err := doBucketVersioning(client, instance, bucket.name, profile.Buckets.Versioning); err != nil {
+				return err
+			}
+		}
+
+		if err := doBucketLifecycle(client, instance, bucket.name, profile.Buckets.Expiration); err != nil {
+			return err
+		}
+
+		// The quotas are set through the MinIO admin API
+		if objectStorage.Type == ObjectStorageMinIO {
+			if err := doBucketQuota(admin, instance, bucket.name, profile.Buckets.Quota); err != nil {
+				return err
+			}
+		} else if profile.Buckets.Quota > 0 {
+			return fmt.Errorf("quotas aren't supported by %s instances", objectStorage.Type)
+		}
+	} else if len(bucket.prefixes) > 0 && objectStorage.Type == ObjectStorageMinIO && objectStorage.vaultMount() != "" {
+		// The statement only allows the users created by the Vault MinIO plugin
+		if err := m.doSharedPolicy(client, instance, bucket, profile.Name, true); err != nil {
+			return err
+		}
+	}
+
+	// Make the folders
+	for _, prefix := range bucket.prefixes {
+		if prefix.placeholder == "" {
+			continue
+		}
+
+		_, err = client.PutObject(context.Background(), bucket.name, path.Join(prefix.name, prefix.placeholder), bytes.NewReader([]byte{}), 0, minio.PutObjectOptions{})
+		observeMinIOOperation(instance, "PutObject", err)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteBucketsForProfile removes the profile's buckets from the MinIO instances.
+// Unless purge is set, objects written by the profile are retained and only
+// the placeholders of its prefixes in the shared buckets are removed.
+func (m *MinIOStruct) DeleteBucketsForProfile(profile MinIOProfile, purge bool) error {
+	return m.forEachInstance(func(instance ObjectStorageInstance) error {
+		buckets, err := m.buckets(instance, profile)
+		if err != nil {
+			return err
+		}
+
+		client, _, err := m.newClient(instance)
+		if err != nil {
+			return fmt.Errorf("reading the credentials: %v", err)
+		}
+
+		for _, bucket := range buckets {
+			if err := m.deleteBucket(client, instance.Name, profile, bucket, purge); err != nil {
+				return fmt.Errorf("bucket %q: %w", bucket.name, err)
+			}
+		}
+
+		if !purge {
+			klog.Infof("retaining data of profile %q in instance %q", profile.Name, instance.Name)
+		}
+
+		return nil
+	}).Err()
+}
+
+func (m *MinIOStruct) deleteBucket(client *minio.Client, instance string, profile MinIOProfile, bucket minioBucket, purge bool) error {
+	if !purge {
+		if bucket.owned {
+			return nil
+		}
+
+		for _, prefix := range bucket.prefixes {
+			if prefix.placeholder == "" {
+				continue
+			}
+
+			err := client.RemoveObject(context.Background(), bucket.name, path.Join(prefix.name, prefix.placeholder), minio.RemoveObjectOptions{})
+			observeMinIOOperation(instance, "RemoveObject", err)
 			if err != nil {
 				return err
 			}
+		}
 
-			if !exists {
-				klog.Infof("making bucket %q in instance %q", bucket, instance)
-				err = client.MakeBucket(context.Background(), bucket, minio.MakeBucketOptions{})
-				if err != nil {
-					return err
-				}
-			} else {
-				klog.Infof("bucket %q in instance %q already exists", bucket, instance)
+		return nil
+	}
+
+	if !bucket.owned {
+		// Empty the shared folders
+		for _, prefix := range bucket.prefixes {
+			if err := removeObjects(client, instance, bucket.name, prefix.name); err != nil {
+				return err
 			}
 		}
 
-		// Make shared folder
-		_, err = client.PutObject(context.Background(), "shared", path.Join(profileName, ".hold"), bytes.NewReader([]byte{}), 0, minio.PutObjectOptions{})
+		// The statement is only removed with the data, so nobody
+		// else can write to the retained shared folders of the profile
+		return m.doSharedPolicy(client, instance, bucket, profile.Name, false)
+	}
+
+	exists, err := client.BucketExists(context.Background(), bucket.name)
+	observeMinIOOperation(instance, "BucketExists", err)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		klog.Infof("bucket %q in instance %q already removed", bucket.name, instance)
+		return nil
+	}
+
+	tags, err := bucketTags(client, instance, bucket.name)
+	if err != nil {
+		return err
+	}
+
+	// Only the buckets tagged with the profile are removed with it
+	if owner := tags[MinIOProfileTag]; owner != profile.Name {
+		klog.Warningf("bucket %q in instance %q isn't tagged with profile %q, keeping it", bucket.name, instance, profile.Name)
+		return nil
+	}
+
+	if err = removeObjects(client, instance, bucket.name, ""); err != nil {
+		return err
+	}
+
+	klog.Infof("removing bucket %q in instance %q", bucket.name, instance)
+	err = client.RemoveBucket(context.Background(), bucket.name)
+	observeMinIOOperation(instance, "RemoveBucket", err)
+	return err
+}
+
+// removeObjects removes every object of the bucket under the given prefix,
+// with their previous versions and delete markers.
+func removeObjects(client *minio.Client, instance, bucket, prefix string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The objects under retention can't be removed
+	objectLock, _, _, _, err := client.GetObjectLockConfig(ctx, bucket)
+	observeMinIOOperation(instance, "GetObjectLockConfig", err)
+	if err != nil && !isMinIOErrorCode(err, "ObjectLockConfigurationNotFoundError") {
+		return err
+	}
+
+	if objectLock == "Enabled" {
+		return ErrMinIOBucketLocked
+	}
+
+	for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true, WithVersions: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+
+		err := client.RemoveObject(ctx, bucket, object.Key, minio.RemoveObjectOptions{VersionID: object.VersionID})
+		observeMinIOOperation(instance, "RemoveObject", err)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/signer"
+)
+
+// minioAdminClient makes the requests of the MinIO admin API
+// which minio-go doesn't cover, such as the bucket quotas.
+type minioAdminClient struct {
+	endpoint        string
+	secure          bool
+	accessKeyID     string
+	secretAccessKey string
+	region          string
+	httpClient      *http.Client
+}
+
+// minioAdminTimeout bounds the requests of the admin API, so an instance
+// which hangs fails them, and is seen as unreachable, instead of blocking
+// a worker.
+const minioAdminTimeout = time.Minute
+
+// newMinIOAdminClient returns an admin client sending its
+// requests through the transport of the S3 client.
+func newMinIOAdminClient(conf *MinIOConfiguration, transport http.RoundTripper) *minioAdminClient {
+	return &minioAdminClient{
+		endpoint:        conf.Endpoint,
+		secure:          conf.UseSSL,
+		accessKeyID:     conf.AccessKeyID,
+		secretAccessKey: conf.SecretAccessKey,
+		region:          conf.Region,
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   minioAdminTimeout,
+		},
+	}
+}
+
+// minioBucketQuota is the quota configuration of a bucket
+type minioBucketQuota struct {
+	Quota     int64  `json:"quota"`
+	QuotaType string `json:"quotatype,omitempty"`
+}
+
+// GetBucketQuota returns the hard quota of the bucket in bytes,
+// zero when the bucket has no quota.
+func (a *minioAdminClient) GetBucketQuota(ctx context.Context, bucket string) (int64, error) {
+	body, status, err := a.do(ctx, http.MethodGet, "get-bucket-quota", bucket, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if status == http.StatusNotFound {
+		return 0, nil
+	}
+
+	quota := minioBucketQuota{}
+	if err := json.Unmarshal(body, &quota); err != nil {
+		return 0, err
+	}
+
+	return quota.Quota, nil
+}
+
+// SetBucketQuota sets the hard quota of the bucket in bytes,
+// the quota is cleared when it's zero.
+func (a *minioAdminClient) SetBucketQuota(ctx context.Context, bucket string, quota int64) error {
+	// An empty configuration clears the quota
+	data := []byte{}
+	if quota > 0 {
+		var err error
+		if data, err = json.Marshal(minioBucketQuota{Quota: quota, QuotaType: "hard"}); err != nil {
+			return err
+		}
+	}
+
+	_, _, err := a.do(ctx, http.MethodPut, "set-bucket-quota", bucket, data)
+	return err
+}
+
+// do sends a request signed with the credentials of the client, and
+// returns the body and status of the response. Statuses other than
+// 200 and 404 are returned as errors.
+func (a *minioAdminClient) do(ctx context.Context, method, operation, bucket string, data []byte) ([]byte, int, error) {
+	scheme := "http"
+	if a.secure {
+		scheme = "https"
+	}
+
+	u := url.URL{
+		Scheme:   scheme,
+		Host:     a.endpoint,
+		Path:     "/minio/admin/v3/" + operation,
+		RawQuery: url.Values{"bucket": []string{bucket}}.Encode(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sum := sha256.Sum256(data)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+
+	region := a.region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	req = signer.SignV4(*req, a.accessKeyID, a.secretAccessKey, "", region)
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return nil, resp.StatusCode, fmt.Errorf("%s %s: %s: %s", method, operation, resp.Status, bytes.TrimSpace(body))
+	}
+
+	return body, resp.StatusCode, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that the requests of the admin API time out, and that the
+// circuit breaker sees the instance which hangs as unreachable
+func TestMinIOAdminClient_timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	admin := newMinIOAdminClient(&MinIOConfiguration{
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	}, http.DefaultTransport)
+
+	if admin.httpClient.Timeout != minioAdminTimeout {
+		t.Errorf("Expected the requests to time out after %s, got %s", minioAdminTimeout, admin.httpClient.Timeout)
+	}
+
+	admin.httpClient.Timeout = 100 * time.Millisecond
+	_, err := admin.GetBucketQuota(context.Background(), "test")
+	if err == nil {
+		t.Fatal("Expected the request to time out")
+	}
+
+	if !isMinIOUnreachable(err) {
+		t.Errorf("Expected the instance to be seen as unreachable, got %v", err)
+	}
+}
+
+func TestMinIOAdminClient_getBucketQuota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/minio/admin/v3/get-bucket-quota" || r.URL.Query().Get("bucket") != "test" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Write([]byte(`{"quota":1024,"quotatype":"hard"}`))
+	}))
+	defer server.Close()
+
+	admin := newMinIOAdminClient(&MinIOConfiguration{
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	}, http.DefaultTransport)
+
+	quota, err := admin.GetBucketQuota(context.Background(), "test")
+	if err != nil || quota != 1024 {
+		t.Errorf("Expected a quota of 1024, got %d and %v", quota, err)
+	}
+
+	if quota, err := admin.GetBucketQuota(context.Background(), "other"); err != nil || quota != 0 {
+		t.Errorf("Expected no quota, got %d and %v", quota, err)
+	}
+}
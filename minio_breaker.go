@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// minioCircuitBreaker skips the MinIO instances which are known to be
+// unreachable for a backoff window, so the sync of every profile doesn't
+// wait on their timeouts.
+type minioCircuitBreaker struct {
+	// threshold is the number of consecutive failures to reach
+	// an instance which opens the breaker, zero disables it.
+	threshold int
+	backoff   time.Duration
+
+	mutex     sync.Mutex
+	instances map[string]*minioBreakerState
+}
+
+type minioBreakerState struct {
+	failures  int
+	openUntil time.Time
+}
+
+func newMinIOCircuitBreaker(threshold int, backoff time.Duration) *minioCircuitBreaker {
+	return &minioCircuitBreaker{
+		threshold: threshold,
+		backoff:   backoff,
+		instances: make(map[string]*minioBreakerState),
+	}
+}
+
+// allow returns an error when the instance should be skipped.
+func (b *minioCircuitBreaker) allow(instance string) error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	state, ok := b.instances[instance]
+	if ok && time.Now().Before(state.openUntil) {
+		return fmt.Errorf("instance is unreachable, skipped until %s", state.openUntil.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// record counts the failures to reach the instance, and opens the breaker
+// once there are enough of them in a row. The other errors, such as the
+// error responses of MinIO, mean the instance is up.
+func (b *minioCircuitBreaker) record(instance string, err error) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	state, ok := b.instances[instance]
+	if !ok {
+		state = &minioBreakerState{}
+		b.instances[instance] = state
+	}
+
+	if !isMinIOUnreachable(err) {
+		if state.failures >= b.threshold {
+			klog.Infof("MinIO instance %q is reachable again", instance)
+		}
+		state.failures = 0
+		return
+	}
+
+	state.failures++
+	if state.failures >= b.threshold {
+		state.openUntil = time.Now().Add(b.backoff)
+		klog.Warningf("MinIO instance %q is unreachable after %d attempts, skipping it until %s: %v", instance, state.failures, state.openUntil.Format(time.RFC3339), err)
+	}
+}
+
+// isMinIOUnreachable returns whether the error is a network error.
+func isMinIOUnreachable(err error) bool {
+	var netErr net.Error
+	return err != nil && errors.As(err, &netErr)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Tests that an instance is only skipped after enough failures to reach it
+// in a row, until its backoff passes or it answers again
+func TestMinIOCircuitBreaker(t *testing.T) {
+	unreachable := fmt.Errorf("bucket %q: %w", "test", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+	breaker := newMinIOCircuitBreaker(2, time.Hour)
+
+	breaker.record("minio_standard", unreachable)
+	if err := breaker.allow("minio_standard"); err != nil {
+		t.Errorf("Expected the instance to be allowed after one failure, got %v", err)
+	}
+
+	// An error response means the instance is up
+	breaker.record("minio_standard", minio.ErrorResponse{Code: "AccessDenied"})
+	breaker.record("minio_standard", unreachable)
+	if err := breaker.allow("minio_standard"); err != nil {
+		t.Errorf("Expected the failures to be reset, got %v", err)
+	}
+
+	breaker.record("minio_standard", unreachable)
+	if err := breaker.allow("minio_standard"); err == nil {
+		t.Error("Expected the instance to be skipped after two failures")
+	}
+
+	if err := breaker.allow("minio_protected_b"); err != nil {
+		t.Errorf("Expected the other instances to be allowed, got %v", err)
+	}
+
+	// Once the backoff passed, a single failure skips it again
+	breaker.instances["minio_standard"].openUntil = time.Now().Add(-time.Second)
+	if err := breaker.allow("minio_standard"); err != nil {
+		t.Errorf("Expected the instance to be allowed after the backoff, got %v", err)
+	}
+
+	breaker.record("minio_standard", unreachable)
+	if err := breaker.allow("minio_standard"); err == nil {
+		t.Error("Expected the instance to be skipped again")
+	}
+
+	breaker.instances["minio_standard"].openUntil = time.Now().Add(-time.Second)
+	breaker.record("minio_standard", nil)
+	breaker.record("minio_standard", unreachable)
+	if err := breaker.allow("minio_standard"); err != nil {
+		t.Errorf("Expected the instance to be allowed once it answered, got %v", err)
+	}
+}
+
+func TestMinIOCircuitBreaker_disabled(t *testing.T) {
+	unreachable := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	breaker := newMinIOCircuitBreaker(0, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		breaker.record("minio_standard", unreachable)
+	}
+
+	if err := breaker.allow("minio_standard"); err != nil {
+		t.Errorf("Expected a disabled breaker to allow every instance, got %v", err)
+	}
+}
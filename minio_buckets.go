@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"github.com/minio/minio-go/v7/pkg/tags"
+	"k8s.io/klog"
+)
+
+// minioErrorCode returns the code of the error response, which may be
+// wrapped, or an empty code when the error isn't an error response.
+func minioErrorCode(err error) string {
+	var response minio.ErrorResponse
+	if errors.As(err, &response) {
+		return response.Code
+	}
+
+	return ""
+}
+
+// isMinIOErrorCode returns whether the error is an error response of the given code.
+func isMinIOErrorCode(err error, code string) bool {
+	return err != nil && minioErrorCode(err) == code
+}
+
+// bucketTags returns the tags of the bucket.
+func bucketTags(client *minio.Client, instance, bucket string) (map[string]string, error) {
+	current, err := client.GetBucketTagging(context.Background(), bucket)
+	observeMinIOOperation(instance, "GetBucketTagging", err)
+	if isMinIOErrorCode(err, "NoSuchTagSet") {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return current.ToMap(), nil
+}
+
+// bucketEmpty returns whether the bucket holds no object, version or delete marker.
+func bucketEmpty(client *minio.Client, instance, bucket string) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for object := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Recursive: true, WithVersions: true, MaxKeys: 1}) {
+		observeMinIOOperation(instance, "ListObjects", object.Err)
+		return false, object.Err
+	}
+
+	observeMinIOOperation(instance, "ListObjects", nil)
+	return true, nil
+}
+
+// doBucketOwner tags the bucket with the profile, unless it belongs to
+// another profile, such as when the names of their buckets collide. An
+// untagged bucket is only adopted while it's empty, or with adopt set.
+func doBucketOwner(client *minio.Client, instance, bucket, profile string, adopt bool) error {
+	current, err := bucketTags(client, instance, bucket)
+	if err != nil {
+		return err
+	}
+
+	empty := true
+	if _, ok := current[MinIOProfileTag]; !ok && !adopt {
+		if empty, err = bucketEmpty(client, instance, bucket); err != nil {
+			return err
+		}
+	}
+
+	updated, err := ownedBucketTags(current, profile, empty || adopt)
+	if err != nil || updated == nil {
+		return err
+	}
+
+	tagging, err := tags.MapToBucketTags(updated)
+	if err != nil {
+		return err
+	}
+
+	if !empty {
+		klog.Warningf("adopting untagged bucket %q in instance %q into profile %q", bucket, instance, profile)
+	}
+
+	klog.Infof("tagging bucket %q in instance %q with profile %q", bucket, instance, profile)
+	err = client.SetBucketTagging(context.Background(), bucket, tagging)
+	observeMinIOOperation(instance, "SetBucketTagging", err)
+	return err
+}
+
+// ownedBucketTags returns the tags of the bucket with the profile tag,
+// or nil when it's already there. The buckets of other profiles are
+// refused, and so are the untagged buckets unless adopt is set, since
+// they may not be managed by the controller.
+func ownedBucketTags(current map[string]string, profile string, adopt bool) (map[string]string, error) {
+	if owner, ok := current[MinIOProfileTag]; ok {
+		if owner != profile {
+			return nil, fmt.Errorf("the bucket belongs to profile %q", owner)
+		}
+		return nil, nil
+	}
+
+	if !adopt {
+		return nil, fmt.Errorf("the bucket holds objects but isn't tagged with %s=%s", MinIOProfileTag, profile)
+	}
+
+	updated := make(map[string]string, len(current)+1)
+	for key, value := range current {
+		updated[key] = value
+	}
+	updated[MinIOProfileTag] = profile
+
+	return updated, nil
+}
+
+// doBucketObjectLock warns about the buckets which should have object locking
+// enabled but don't, since it can only be enabled when the bucket is created.
+func doBucketObjectLock(client *minio.Client, instance, bucket string, enabled bool) error {
+	if !enabled {
+		return nil
+	}
+
+	objectLock, _, _, _, err := client.GetObjectLockConfig(context.Background(), bucket)
+	observeMinIOOperation(instance, "GetObjectLockConfig", err)
+	if err != nil && !isMinIOErrorCode(err, "ObjectLockConfigurationNotFoundError") {
+		return err
+	}
+
+	if objectLock != "Enabled" {
+		klog.Warningf("bucket %q in instance %q was created without object lock, it can't be enabled anymore", bucket, instance)
+	}
+
+	return nil
+}
+
+// doBucketVersioning enables or suspends the versioning of the bucket.
+func doBucketVersioning(client *minio.Client, instance, bucket string, enabled bool) error {
+	versioning, err := client.GetBucketVersioning(context.Background(), bucket)
+	observeMinIOOperation(instance, "GetBucketVersioning", err)
+	if err != nil {
+		return err
+	}
+
+	if enabled && versioning.Status != "Enabled" {
+		klog.Infof("enabling the versioning of bucket %q in instance %q", bucket, instance)
+		err = client.EnableVersioning(context.Background(), bucket)
+		observeMinIOOperation(instance, "EnableVersioning", err)
+	} else if !enabled && versioning.Status == "Enabled" {
+		// Versioning can't be disabled once it was enabled
+		klog.Infof("suspending the versioning of bucket %q in instance %q", bucket, instance)
+		err = client.SuspendVersioning(context.Background(), bucket)
+		observeMinIOOperation(instance, "SuspendVersioning", err)
+	}
+
+	return err
+}
+
+// minioLifecycleRulePrefix prefixes the IDs of the lifecycle rules
+// managed by the controller. The other rules of a bucket are kept.
+const minioLifecycleRulePrefix = "expire-"
+
+// doBucketLifecycle sets the lifecycle rules of the controller to the
+// expiration of each prefix, and keeps the rules added by others. The
+// lifecycle of the bucket is removed when no rule is left.
+func doBucketLifecycle(client *minio.Client, instance, bucket string, expiration map[string]int) error {
+	current, err := client.GetBucketLifecycle(context.Background(), bucket)
+	observeMinIOOperation(instance, "GetBucketLifecycle", err)
+	if err != nil && !isMinIOErrorCode(err, "NoSuchLifecycleConfiguration") {
+		return err
+	}
+
+	config := updatedBucketLifecycle(current, expiration)
+	if config == nil {
+		return nil
+	}
+
+	klog.Infof("setting the lifecycle of bucket %q in instance %q to %d rules", bucket, instance, len(config.Rules))
+	err = client.SetBucketLifecycle(context.Background(), bucket, config)
+	observeMinIOOperation(instance, "SetBucketLifecycle", err)
+	return err
+}
+
+// updatedBucketLifecycle returns the lifecycle with the rules of the
+// controller replaced by the expiration of each prefix, or nil when
+// they already match.
+func updatedBucketLifecycle(current *lifecycle.Configuration, expiration map[string]int) *lifecycle.Configuration {
+	config := lifecycle.NewConfiguration()
+	actual := make(map[string]int)
+	if current != nil {
+		for _, rule := range current.Rules {
+			if !strings.HasPrefix(rule.ID, minioLifecycleRulePrefix) {
+				config.Rules = append(config.Rules, rule)
+				continue
+			}
+
+			if rule.Status != "Enabled" || rule.Expiration.Days <= 0 {
+				// A disabled rule never matches, so it's rewritten
+				actual[rule.ID] = 0
+				continue
+			}
+
+			prefix := rule.RuleFilter.Prefix
+			if prefix == "" {
+				prefix = rule.Prefix
+			}
+			actual[prefix] = int(rule.Expiration.Days)
+		}
+	}
+
+	if len(actual) == len(expiration) && (len(actual) == 0 || reflect.DeepEqual(actual, expiration)) {
+		return nil
+	}
+
+	prefixes := make([]string, 0, len(expiration))
+	for prefix := range expiration {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	for _, prefix := range prefixes {
+		config.Rules = append(config.Rules, lifecycle.Rule{
+			ID:     minioLifecycleRulePrefix + strings.TrimSuffix(prefix, "/"),
+			Status: "Enabled",
+			RuleFilter: lifecycle.Filter{
+				Prefix: prefix,
+			},
+			Expiration: lifecycle.Expiration{
+				Days: lifecycle.ExpirationDays(expiration[prefix]),
+			},
+		})
+	}
+
+	return config
+}
+
+// doBucketQuota sets the hard quota of the bucket, in bytes.
+func doBucketQuota(admin *minioAdminClient, instance, bucket string, quota int64) error {
+	current, err := admin.GetBucketQuota(context.Background(), bucket)
+	observeMinIOOperation(instance, "GetBucketQuota", err)
+	if err != nil {
+		return err
+	}
+
+	if current == quota {
+		return nil
+	}
+
+	klog.Infof("setting the quota of bucket %q in instance %q to %d bytes", bucket, instance, quota)
+	err = admin.SetBucketQuota(context.Background(), bucket, quota)
+	observeMinIOOperation(instance, "SetBucketQuota", err)
+	return err
+}
+
+// bucketPolicy is an S3 bucket policy. Its statements are kept
+// as they're read, so the ones of others are written back as-is.
+type bucketPolicy struct {
+	Version   string                   `json:"Version"`
+	Statement []map[string]interface{} `json:"Statement"`
+}
+
+// sharedPolicyStatement denies the writes to the prefixes of the profile
+// in a shared bucket to every user but the ones created for the profile by Vault.
+func sharedPolicyStatement(bucket minioBucket, profileName string) (map[string]interface{}, error) {
+	resources := make([]string, 0, len(bucket.prefixes))
+	for _, prefix := range bucket.prefixes {
+		resources = append(resources, fmt.Sprintf("arn:aws:s3:::%s/%s*", bucket.name, prefix.name))
+	}
+
+	statement := map[string]interface{}{
+		"Sid":    fmt.Sprintf("%s-%s", bucket.name, profileName),
+		"Effect": "Deny",
+		"Principal": map[string][]string{
+			"AWS": {"*"},
+		},
+		"Action":   []string{"s3:PutObject", "s3:DeleteObject"},
+		"Resource": resources,
+		"Condition": map[string]map[string][]string{
+			"StringNotLike": {
+				"aws:username": {minioUserNamePrefix(fmt.Sprintf("profile-%s", profileName)) + "*"},
+			},
+		},
+	}
+
+	// Round-trip the statement so it compares with the ones read
+	data, err := json.Marshal(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{})
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+// doSharedPolicy adds, or removes, the statement of the profile
+// to the policy of a shared bucket.
+func (m *MinIOStruct) doSharedPolicy(client *minio.Client, instance string, bucket minioBucket, profileName string, present bool) error {
+	m.policyMutex.Lock()
+	defer m.policyMutex.Unlock()
+
+	current, err := client.GetBucketPolicy(context.Background(), bucket.name)
+	observeMinIOOperation(instance, "GetBucketPolicy", err)
+	if err != nil && !isMinIOErrorCode(err, "NoSuchBucketPolicy") {
+		return err
+	}
+
+	policy := bucketPolicy{Version: "2012-10-17"}
+	if current != "" {
+		if err := json.Unmarshal([]byte(current), &policy); err != nil {
+			return fmt.Errorf("invalid policy of bucket %q: %v", bucket.name, err)
+		}
+	}
+
+	desired, err := sharedPolicyStatement(bucket, profileName)
+	if err != nil {
+		return err
+	}
+
+	var found map[string]interface{}
+	statements := make([]map[string]interface{}, 0, len(policy.Statement)+1)
+	for _, statement := range policy.Statement {
+		if statement["Sid"] == desired["Sid"] {
+			found = statement
+			continue
+		}
+
+		statements = append(statements, statement)
+	}
+
+	if present {
+		if reflect.DeepEqual(found, desired) {
+			return nil
+		}
+
+		statements = append(statements, desired)
+	} else if found == nil {
+		return nil
+	}
+
+	// An empty policy removes the policy of the bucket
+	data := []byte{}
+	if len(statements) > 0 {
+		policy.Statement = statements
+		if data, err = json.Marshal(policy); err != nil {
+			return err
+		}
+	}
+
+	klog.Infof("updating the statement of profile %q in the policy of bucket %q in instance %q", profileName, bucket.name, instance)
+	err = client.SetBucketPolicy(context.Background(), bucket.name, string(data))
+	observeMinIOOperation(instance, "SetBucketPolicy", err)
+	return err
+}
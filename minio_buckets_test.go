@@ -0,0 +1,166 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// Tests that the statement of a profile only lets its own users write to
+// its prefixes, even when the name of another profile starts with its name
+func TestSharedPolicyStatement(t *testing.T) {
+	bucket := minioBucket{
+		name: "shared",
+		prefixes: []minioPrefix{
+			{name: "alice/"},
+		},
+	}
+
+	statement, err := sharedPolicyStatement(bucket, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statement["Sid"] != "shared-alice" || statement["Effect"] != "Deny" {
+		t.Errorf("Unexpected statement %v", statement)
+	}
+
+	resources := statement["Resource"].([]interface{})
+	if len(resources) != 1 || resources[0] != "arn:aws:s3:::shared/alice/*" {
+		t.Errorf("Unexpected resources %v", resources)
+	}
+
+	condition := statement["Condition"].(map[string]interface{})["StringNotLike"].(map[string]interface{})
+	patterns := condition["aws:username"].([]interface{})
+	if len(patterns) != 1 {
+		t.Fatalf("Unexpected condition %v", condition)
+	}
+
+	// StringNotLike is a prefix match for a trailing wildcard
+	pattern := patterns[0].(string)
+	if !strings.HasSuffix(pattern, "*") || strings.Count(pattern, "*") != 1 {
+		t.Fatalf("Expected a single trailing wildcard, got %q", pattern)
+	}
+	prefix := strings.TrimSuffix(pattern, "*")
+
+	users := map[string]bool{
+		minioUserNamePrefix("profile-alice") + "x1y2":     true,
+		minioUserNamePrefix("profile-alice-bob") + "x1y2": false,
+		minioUserNamePrefix("profile-alice2") + "x1y2":    false,
+		"profile-alice-x1y2":                              false,
+	}
+
+	for user, allowed := range users {
+		if strings.HasPrefix(user, prefix) != allowed {
+			t.Errorf("Expected user %q allowed=%v by %q", user, allowed, pattern)
+		}
+	}
+}
+
+func newTestLifecycleRule(id, prefix string, days int) lifecycle.Rule {
+	return lifecycle.Rule{
+		ID:         id,
+		Status:     "Enabled",
+		RuleFilter: lifecycle.Filter{Prefix: prefix},
+		Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(days)},
+	}
+}
+
+// Tests that only the rules of the controller are rewritten,
+// and that the rules added by others are kept
+func TestUpdatedBucketLifecycle(t *testing.T) {
+	foreign := newTestLifecycleRule("archive", "logs/", 365)
+	tmp := newTestLifecycleRule("expire-tmp", "tmp/", 30)
+
+	tests := map[string]struct {
+		current    *lifecycle.Configuration
+		expiration map[string]int
+		expected   []lifecycle.Rule
+		updated    bool
+	}{
+		"none": {
+			expiration: map[string]int{},
+		},
+		"unchanged": {
+			current:    &lifecycle.Configuration{Rules: []lifecycle.Rule{foreign, tmp}},
+			expiration: map[string]int{"tmp/": 30},
+		},
+		"foreign only": {
+			current:    &lifecycle.Configuration{Rules: []lifecycle.Rule{foreign}},
+			expiration: map[string]int{},
+		},
+		"added": {
+			current:    &lifecycle.Configuration{Rules: []lifecycle.Rule{foreign}},
+			expiration: map[string]int{"tmp/": 30},
+			expected:   []lifecycle.Rule{foreign, tmp},
+			updated:    true,
+		},
+		"changed": {
+			current:    &lifecycle.Configuration{Rules: []lifecycle.Rule{newTestLifecycleRule("expire-tmp", "tmp/", 7)}},
+			expiration: map[string]int{"tmp/": 30},
+			expected:   []lifecycle.Rule{tmp},
+			updated:    true,
+		},
+		"disabled": {
+			current: &lifecycle.Configuration{Rules: []lifecycle.Rule{
+				{ID: "expire-tmp", Status: "Disabled", RuleFilter: lifecycle.Filter{Prefix: "tmp/"}, Expiration: lifecycle.Expiration{Days: 30}},
+			}},
+			expiration: map[string]int{"tmp/": 30},
+			expected:   []lifecycle.Rule{tmp},
+			updated:    true,
+		},
+		"removed": {
+			current:    &lifecycle.Configuration{Rules: []lifecycle.Rule{foreign, tmp}},
+			expiration: map[string]int{},
+			expected:   []lifecycle.Rule{foreign},
+			updated:    true,
+		},
+		"removed all": {
+			current:    &lifecycle.Configuration{Rules: []lifecycle.Rule{tmp}},
+			expiration: map[string]int{},
+			updated:    true,
+		},
+	}
+
+	for name, test := range tests {
+		config := updatedBucketLifecycle(test.current, test.expiration)
+		if (config != nil) != test.updated {
+			t.Errorf("%s: expected updated=%v, got %v", name, test.updated, config)
+			continue
+		}
+
+		if config != nil && !reflect.DeepEqual(config.Rules, test.expected) {
+			t.Errorf("%s: expected the rules %v, got %v", name, test.expected, config.Rules)
+		}
+	}
+}
+
+// Tests that the buckets are tagged with their profile, and that the
+// buckets of other profiles and the untagged buckets in use are refused
+func TestOwnedBucketTags(t *testing.T) {
+	tags, err := ownedBucketTags(map[string]string{"team": "daaas"}, "alice", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"team": "daaas", MinIOProfileTag: "alice"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Expected %v, got %v", expected, tags)
+	}
+
+	for _, adopt := range []bool{true, false} {
+		if tags, err := ownedBucketTags(expected, "alice", adopt); tags != nil || err != nil {
+			t.Errorf("Expected no update, got %v and %v", tags, err)
+		}
+
+		if _, err := ownedBucketTags(expected, "bob", adopt); err == nil {
+			t.Error("Expected the bucket of another profile to be refused")
+		}
+	}
+
+	if _, err := ownedBucketTags(map[string]string{"team": "daaas"}, "alice", false); err == nil {
+		t.Error("Expected an untagged bucket holding objects to be refused")
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"k8s.io/klog"
+)
+
+// minioClientCache keeps the clients of the object storage instances, so their
+// configuration isn't read, from Vault, for every profile on every sync.
+type minioClientCache struct {
+	configuration func(instance ObjectStorageInstance) (*MinIOConfiguration, error)
+	// ttl is how long the configuration of an instance is used before
+	// it's read again, or less when the lease of its secret is shorter.
+	ttl time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]*minioClientEntry
+}
+
+type minioClientEntry struct {
+	conf    MinIOConfiguration
+	client  *minio.Client
+	admin   *minioAdminClient
+	expires time.Time
+}
+
+func newMinIOClientCache(configuration func(instance ObjectStorageInstance) (*MinIOConfiguration, error), ttl time.Duration) *minioClientCache {
+	return &minioClientCache{
+		configuration: configuration,
+		ttl:           ttl,
+		entries:       make(map[string]*minioClientEntry),
+	}
+}
+
+// get returns the clients of the instance, reading its configuration
+// when it isn't cached or has expired. The clients are only created
+// again when the configuration changed.
+func (c *minioClientCache) get(instance ObjectStorageInstance) (*minio.Client, *minioAdminClient, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	entry, ok := c.entries[instance.Name]
+	if ok && now.Before(entry.expires) {
+		return entry.client, entry.admin, nil
+	}
+
+	conf, err := c.configuration(instance)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ttl := c.ttl
+	if conf.LeaseDuration > 0 && conf.LeaseDuration < ttl {
+		ttl = conf.LeaseDuration
+	}
+
+	if ok && entry.conf == *conf {
+		entry.expires = now.Add(ttl)
+		return entry.client, entry.admin, nil
+	}
+
+	bucketLookup := minio.BucketLookupAuto
+	if conf.PathStyle {
+		bucketLookup = minio.BucketLookupPath
+	}
+
+	transport, err := minio.DefaultTransport(conf.UseSSL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := minio.New(conf.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(conf.AccessKeyID, conf.SecretAccessKey, ""),
+		Secure:       conf.UseSSL,
+		Transport:    transport,
+		Region:       conf.Region,
+		BucketLookup: bucketLookup,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if ok {
+		klog.Infof("the configuration of object storage instance %q changed, refreshing its clients", instance.Name)
+	}
+
+	entry = &minioClientEntry{
+		conf:    *conf,
+		client:  client,
+		admin:   newMinIOAdminClient(conf, transport),
+		expires: now.Add(ttl),
+	}
+	c.entries[instance.Name] = entry
+
+	return entry.client, entry.admin, nil
+}
+
+// invalidate drops the clients of the instance, so its
+// configuration is read again on the next call.
+func (c *minioClientCache) invalidate(instance string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, instance)
+}
+
+// isMinIOCredentialsError returns whether the error is caused by
+// credentials which were rotated or revoked.
+func isMinIOCredentialsError(err error) bool {
+	switch minioErrorCode(err) {
+	case "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken", "InvalidToken":
+		return true
+	}
+
+	return false
+}
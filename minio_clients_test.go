@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Tests that the credentials errors are recognized once
+// they're wrapped with the bucket they happened on
+func TestIsMinIOCredentialsError(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"nil":             {err: nil},
+		"other":           {err: errors.New("connection refused")},
+		"other code":      {err: minio.ErrorResponse{Code: "NoSuchBucket"}},
+		"invalid key":     {err: minio.ErrorResponse{Code: "InvalidAccessKeyId"}, expected: true},
+		"wrapped":         {err: fmt.Errorf("bucket %q: %w", "test", minio.ErrorResponse{Code: "SignatureDoesNotMatch"}), expected: true},
+		"wrapped twice":   {err: fmt.Errorf("instance: %w", fmt.Errorf("bucket %q: %w", "test", minio.ErrorResponse{Code: "ExpiredToken"})), expected: true},
+		"wrapped with %v": {err: fmt.Errorf("bucket %q: %v", "test", minio.ErrorResponse{Code: "InvalidToken"})},
+	}
+
+	for name, test := range tests {
+		if isMinIOCredentialsError(test.err) != test.expected {
+			t.Errorf("%s: expected %v for %v", name, test.expected, test.err)
+		}
+	}
+
+	if !isMinIOErrorCode(fmt.Errorf("bucket %q: %w", "test", minio.ErrorResponse{Code: "NoSuchTagSet"}), "NoSuchTagSet") {
+		t.Error("Expected the code of a wrapped error response")
+	}
+}
+
+// Tests that the configuration is only read again once it expired or was
+// invalidated, and that the clients are only replaced when it changed
+func TestMinIOClientCache(t *testing.T) {
+	instance := ObjectStorageInstance{Name: "minio_standard"}
+	conf := MinIOConfiguration{Endpoint: "minio.example.com", AccessKeyID: "key", SecretAccessKey: "secret"}
+
+	reads := 0
+	var readErr error
+	cache := newMinIOClientCache(func(ObjectStorageInstance) (*MinIOConfiguration, error) {
+		reads++
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		current := conf
+		return &current, nil
+	}, time.Hour)
+
+	expire := func() {
+		cache.entries[instance.Name].expires = time.Now().Add(-time.Second)
+	}
+
+	client, admin, err := cache.get(instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client == nil || admin == nil || reads != 1 {
+		t.Fatalf("Expected the clients to be created, got %d reads", reads)
+	}
+
+	if cached, _, _ := cache.get(instance); cached != client || reads != 1 {
+		t.Errorf("Expected the cached client, got %d reads", reads)
+	}
+
+	// The same configuration keeps the clients
+	expire()
+	if cached, _, _ := cache.get(instance); cached != client || reads != 2 {
+		t.Errorf("Expected the configuration to be read again and the client kept, got %d reads", reads)
+	}
+
+	if expires := cache.entries[instance.Name].expires; time.Until(expires) < 59*time.Minute {
+		t.Errorf("Expected the expiration to be extended, got %v", expires)
+	}
+
+	// A rotated secret replaces the clients
+	conf.SecretAccessKey = "rotated"
+	expire()
+	rotated, _, _ := cache.get(instance)
+	if rotated == client || reads != 3 {
+		t.Errorf("Expected a new client, got %d reads", reads)
+	}
+
+	// A shorter lease shortens the TTL
+	conf.LeaseDuration = time.Minute
+	cache.invalidate(instance.Name)
+	if _, _, err := cache.get(instance); err != nil || reads != 4 {
+		t.Errorf("Expected the configuration to be read again, got %d reads and %v", reads, err)
+	}
+
+	if expires := cache.entries[instance.Name].expires; time.Until(expires) > time.Minute {
+		t.Errorf("Expected the lease to shorten the expiration, got %v", expires)
+	}
+
+	// The errors aren't cached
+	readErr = errors.New("vault sealed")
+	expire()
+	if _, _, err := cache.get(instance); err != readErr {
+		t.Errorf("Expected the read error, got %v", err)
+	}
+
+	readErr = nil
+	if _, _, err := cache.get(instance); err != nil || reads != 6 {
+		t.Errorf("Expected the configuration to be read again, got %d reads and %v", reads, err)
+	}
+}
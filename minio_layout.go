@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// MinIOBucketLayout lists the buckets of a profile in a MinIO instance.
+type MinIOBucketLayout struct {
+	Buckets []MinIOBucketSpec `json:"buckets"`
+}
+
+// MinIOBucketSpec describes a bucket of a profile. Its name is a Go template
+// rendered with the name of the profile ({{ .ProfileName }}) and its owner
+// ({{ .Owner }}), and made a valid S3 bucket name.
+type MinIOBucketSpec struct {
+	Name string `json:"name"`
+	// Owned buckets belong to the profile: they get the configuration of
+	// the bucket of the profile and are removed with it. The other buckets
+	// are shared by the profiles, which each get prefixes in them.
+	Owned bool `json:"owned,omitempty"`
+	// Prefixes of the profile in the bucket. Writes to the prefixes of a
+	// shared bucket are limited to the profile by the bucket policy.
+	Prefixes []MinIOPrefixSpec `json:"prefixes,omitempty"`
+}
+
+// MinIOPrefixSpec describes a prefix of a profile in a bucket. Its
+// name is a Go template rendered like the names of the buckets.
+type MinIOPrefixSpec struct {
+	Name string `json:"name"`
+	// Placeholder is an object created under the prefix, so
+	// the prefix shows up as a folder before it holds anything.
+	Placeholder string `json:"placeholder,omitempty"`
+}
+
+// DefaultMinIOBucketLayout is the layout of the instances without
+// one of their own: a bucket named after the profile and a folder
+// of the profile in the shared bucket.
+var DefaultMinIOBucketLayout = MinIOBucketLayout{
+	Buckets: []MinIOBucketSpec{
+		{
+			Name:  "{{ .ProfileName }}",
+			Owned: true,
+		},
+		{
+			Name: SHARED_BUCKET,
+			Prefixes: []MinIOPrefixSpec{
+				{
+					Name:        "{{ .ProfileName }}/",
+					Placeholder: ".hold",
+				},
+			},
+		},
+	},
+}
+
+// LoadMinIOBucketLayouts reads the bucket layouts of the MinIO instances,
+// a YAML or JSON map of instance names to their layout.
+func LoadMinIOBucketLayouts(filename string) (map[string]MinIOBucketLayout, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	layouts := make(map[string]MinIOBucketLayout)
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(&layouts); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	// Catch the invalid templates before any profile is synced
+	data := profileTemplateData{ProfileName: "profile", Owner: "owner@example.com"}
+	for instance, layout := range layouts {
+		if _, err := layout.render(data); err != nil {
+			return nil, fmt.Errorf("%s: instance %q: %v", filename, instance, err)
+		}
+	}
+
+	return layouts, nil
+}
+
+// minioBucket is a bucket of a layout rendered for a profile
+type minioBucket struct {
+	name     string
+	owned    bool
+	prefixes []minioPrefix
+}
+
+// minioPrefix is a prefix of a layout rendered for a profile
+type minioPrefix struct {
+	name        string
+	placeholder string
+}
+
+// render renders the names of the buckets and prefixes of the layout.
+func (l MinIOBucketLayout) render(data profileTemplateData) ([]minioBucket, error) {
+	buckets := make([]minioBucket, 0, len(l.Buckets))
+	names := make([]string, 0, len(l.Buckets))
+	for _, spec := range l.Buckets {
+		name, err := renderProfileTemplate(spec.Name, data)
+		if err != nil {
+			return nil, fmt.Errorf("bucket %q: %v", spec.Name, err)
+		}
+
+		bucket := minioBucket{
+			name:  s3BucketName(name),
+			owned: spec.Owned,
+		}
+
+		// The shared bucket must never be configured or removed with a profile
+		if bucket.owned && bucket.name == SHARED_BUCKET {
+			return nil, fmt.Errorf("bucket %q is reserved", bucket.name)
+		}
+
+		if StringArrayContains(names, bucket.name) {
+			return nil, fmt.Errorf("bucket %q is listed more than once", bucket.name)
+		}
+		names = append(names, bucket.name)
+
+		for _, prefixSpec := range spec.Prefixes {
+			prefix, err := renderProfileTemplate(prefixSpec.Name, data)
+			if err != nil {
+				return nil, fmt.Errorf("bucket %q: prefix %q: %v", spec.Name, prefixSpec.Name, err)
+			}
+
+			// A prefix of a shared bucket must not cover the prefixes of other profiles
+			prefix = strings.TrimPrefix(prefix, "/")
+			if prefix == "" && !spec.Owned {
+				return nil, fmt.Errorf("bucket %q: prefix %q is empty", spec.Name, prefixSpec.Name)
+			}
+			if prefix != "" && !strings.HasSuffix(prefix, "/") {
+				prefix += "/"
+			}
+			if err := validatePrefix(prefix); err != nil {
+				return nil, fmt.Errorf("bucket %q: prefix %q: %v", spec.Name, prefixSpec.Name, err)
+			}
+
+			for _, other := range bucket.prefixes {
+				if other.name == prefix {
+					return nil, fmt.Errorf("bucket %q: prefix %q is listed more than once", spec.Name, prefix)
+				}
+			}
+
+			bucket.prefixes = append(bucket.prefixes, minioPrefix{
+				name:        prefix,
+				placeholder: prefixSpec.Placeholder,
+			})
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, nil
+}
+
+// validatePrefix rejects the prefixes escaping their folder once they're
+// joined to the placeholders, and the wildcards of the bucket policies.
+func validatePrefix(prefix string) error {
+	if strings.ContainsAny(prefix, "*?$") {
+		return fmt.Errorf("wildcards and variables aren't allowed")
+	}
+
+	for _, segment := range strings.Split(strings.TrimSuffix(prefix, "/"), "/") {
+		if segment == "." || segment == ".." || (segment == "" && prefix != "") {
+			return fmt.Errorf("empty, . and .. folders aren't allowed")
+		}
+	}
+
+	return nil
+}
+
+var invalidBucketNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// s3BucketName makes a valid S3 bucket name of the name: 3 to 63 lowercase
+// letters, numbers and dashes, starting and ending with a letter or number.
+// The names which had to be cleaned, shortened or padded get a hash of the
+// original name, so different names never make the same bucket name. Dots
+// are replaced too since they break the virtual-hosted style requests over TLS.
+func s3BucketName(name string) string {
+	lowered := strings.ToLower(name)
+	cleaned := invalidBucketNameChars.ReplaceAllString(lowered, "-")
+	cleaned = strings.Trim(cleaned, "-")
+
+	if cleaned == lowered && len(cleaned) >= 3 && len(cleaned) <= 63 {
+		return cleaned
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:8]
+
+	if len(cleaned) > 63-len(hash)-1 {
+		cleaned = strings.TrimRight(cleaned[:63-len(hash)-1], "-")
+	}
+
+	if cleaned == "" {
+		return hash
+	}
+
+	return cleaned + "-" + hash
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var validBucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`)
+
+func TestS3BucketName(t *testing.T) {
+	tests := map[string]string{
+		"alice":                  "alice",
+		"Alice":                  "alice",
+		"alice-bob":              "alice-bob",
+		"a.b@x.ca":               "a-b-x-ca-",
+		"-alice-":                "alice-",
+		"ab":                     "ab-",
+		"":                       "",
+		strings.Repeat("a", 63):  strings.Repeat("a", 63),
+		strings.Repeat("a", 64):  strings.Repeat("a", 54) + "-",
+		strings.Repeat("a.", 40): strings.Repeat("a-", 27),
+	}
+
+	for name, expected := range tests {
+		bucket := s3BucketName(name)
+		if !strings.HasPrefix(bucket, expected) {
+			t.Errorf("Expected %q to start with %q, got %q", name, expected, bucket)
+		}
+
+		if !validBucketName.MatchString(bucket) {
+			t.Errorf("Expected a valid bucket name for %q, got %q", name, bucket)
+		}
+	}
+
+	// The names which collide once cleaned are told apart by their hash
+	collisions := [][]string{
+		{"a.b@x.ca", "a_b@x.ca", "a-b-x-ca"},
+		{"ab", "AB!"},
+		{strings.Repeat("a", 64), strings.Repeat("a", 65)},
+	}
+
+	for _, names := range collisions {
+		buckets := make(map[string]string)
+		for _, name := range names {
+			bucket := s3BucketName(name)
+			if other, ok := buckets[bucket]; ok {
+				t.Errorf("Expected %q and %q to get different buckets, got %q", other, name, bucket)
+			}
+			buckets[bucket] = name
+		}
+	}
+}
+
+// Tests that the names of the buckets and prefixes are rendered
+// for the profile, and that the invalid layouts are rejected
+func TestMinIOBucketLayoutRender(t *testing.T) {
+	data := profileTemplateData{ProfileName: "alice", Owner: "Alice.Smith@example.com"}
+
+	buckets, err := DefaultMinIOBucketLayout.render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []minioBucket{
+		{name: "alice", owned: true},
+		{name: "shared", prefixes: []minioPrefix{{name: "alice/", placeholder: ".hold"}}},
+	}
+	if !reflect.DeepEqual(buckets, expected) {
+		t.Errorf("Expected %v, got %v", expected, buckets)
+	}
+
+	layout := MinIOBucketLayout{
+		Buckets: []MinIOBucketSpec{
+			{
+				Name:  "{{ .Owner }}",
+				Owned: true,
+				Prefixes: []MinIOPrefixSpec{
+					{Name: ""},
+					{Name: "/tmp"},
+				},
+			},
+		},
+	}
+
+	buckets, err = layout.render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(buckets) != 1 || !strings.HasPrefix(buckets[0].name, "alice-smith-example-com-") {
+		t.Fatalf("Expected the bucket of the owner, got %v", buckets)
+	}
+
+	if !reflect.DeepEqual(buckets[0].prefixes, []minioPrefix{{name: ""}, {name: "tmp/"}}) {
+		t.Errorf("Expected the prefixes to be relative folders, got %v", buckets[0].prefixes)
+	}
+
+	invalidLayouts := map[string][]MinIOBucketSpec{
+		"unknown field": {
+			{Name: "{{ .Unknown }}"},
+		},
+		"duplicate bucket": {
+			{Name: "{{ .ProfileName }}", Owned: true},
+			{Name: "Alice"},
+		},
+		"reserved bucket": {
+			{Name: "Shared", Owned: true},
+		},
+		"shared bucket of the layout": {
+			{Name: "team-{{ .ProfileName }}", Owned: true},
+			{Name: "team-alice"},
+		},
+		"empty shared prefix": {
+			{Name: "shared", Prefixes: []MinIOPrefixSpec{{Name: "/"}}},
+		},
+		"duplicate prefix": {
+			{Name: "shared", Prefixes: []MinIOPrefixSpec{{Name: "{{ .ProfileName }}"}, {Name: "alice/"}}},
+		},
+		"escaping prefix": {
+			{Name: "shared", Prefixes: []MinIOPrefixSpec{{Name: "{{ .ProfileName }}/../bob/"}}},
+		},
+		"dot prefix": {
+			{Name: "shared", Prefixes: []MinIOPrefixSpec{{Name: "./{{ .ProfileName }}"}}},
+		},
+		"empty folder": {
+			{Name: "shared", Prefixes: []MinIOPrefixSpec{{Name: "alice//tmp"}}},
+		},
+		"wildcard prefix": {
+			{Name: "shared", Prefixes: []MinIOPrefixSpec{{Name: "{{ .ProfileName }}*"}}},
+		},
+	}
+
+	// The bucket of a profile named shared would be the shared bucket
+	if _, err := DefaultMinIOBucketLayout.render(profileTemplateData{ProfileName: "shared"}); err == nil {
+		t.Error("Expected the bucket of a profile named shared to be refused")
+	}
+
+	for name, specs := range invalidLayouts {
+		if _, err := (MinIOBucketLayout{Buckets: specs}).render(data); err == nil {
+			t.Errorf("Expected the %s layout to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Tests that a locked bucket can be told apart from the
+// errors of the other instances once they're aggregated
+func TestMinIOResultsErr(t *testing.T) {
+	results := MinIOResults{
+		"minio_standard":    nil,
+		"minio_protected_b": fmt.Errorf("bucket %q: %w", "test", ErrMinIOBucketLocked),
+	}
+
+	err := results.Err()
+	if err == nil || !errors.Is(err, ErrMinIOBucketLocked) {
+		t.Errorf("Expected the locked bucket error, got %v", err)
+	}
+
+	if err := (MinIOResults{"minio_standard": nil}).Err(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if err := (MinIOResults{"minio_standard": errors.New("unreachable")}).Err(); errors.Is(err, ErrMinIOBucketLocked) {
+		t.Errorf("Expected another error, got %v", err)
+	}
+}
+
+func TestParseMinIOExpiration(t *testing.T) {
+	tests := map[string]map[string]int{
+		"":                    {},
+		"tmp/=30":             {"tmp/": 30},
+		" tmp/ = 30, logs/=7": {"tmp/": 30, "logs/": 7},
+		"=1":                  {"": 1},
+	}
+
+	for value, expected := range tests {
+		expiration, err := ParseMinIOExpiration(value)
+		if err != nil {
+			t.Errorf("Expected %q to be parsed, got %v", value, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(expiration, expected) {
+			t.Errorf("Expected %v for %q, got %v", expected, value, expiration)
+		}
+	}
+
+	for _, value := range []string{"tmp/", "tmp/=", "tmp/=0", "tmp/=-1", "tmp/=soon"} {
+		if _, err := ParseMinIOExpiration(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+// Tests that the annotations of the Profile override the defaults
+func TestMinIOBucketConfig(t *testing.T) {
+	defaults := MinIOBucketConfig{
+		Quota:      1024,
+		Expiration: map[string]int{"tmp/": 30},
+	}
+
+	tests := map[string]struct {
+		annotations map[string]string
+		expected    MinIOBucketConfig
+	}{
+		"defaults": {
+			expected: defaults,
+		},
+		"overridden": {
+			annotations: map[string]string{
+				MinIOQuotaAnnotation:      "1Ki",
+				MinIOVersioningAnnotation: "true",
+				MinIOObjectLockAnnotation: "true",
+				MinIOExpirationAnnotation: "logs/=7",
+			},
+			expected: MinIOBucketConfig{
+				Quota:      1024,
+				Versioning: true,
+				ObjectLock: true,
+				Expiration: map[string]int{"logs/": 7},
+			},
+		},
+		"no expiration": {
+			annotations: map[string]string{MinIOExpirationAnnotation: ""},
+			expected:    MinIOBucketConfig{Quota: 1024, Expiration: map[string]int{}},
+		},
+	}
+
+	for name, test := range tests {
+		profile := newTestProfile()
+		profile.ObjectMeta = metav1.ObjectMeta{Name: "test", Annotations: test.annotations}
+
+		config, err := minioBucketConfig(profile, defaults)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(config, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", name, test.expected, config)
+		}
+	}
+
+	invalidAnnotations := map[string]string{
+		MinIOQuotaAnnotation:      "lots",
+		MinIOVersioningAnnotation: "maybe",
+		MinIOObjectLockAnnotation: "maybe",
+		MinIOExpirationAnnotation: "tmp/",
+	}
+
+	for annotation, value := range invalidAnnotations {
+		profile := newTestProfile()
+		profile.Annotations = map[string]string{annotation: value}
+
+		if _, err := minioBucketConfig(profile, defaults); err == nil {
+			t.Errorf("Expected %s=%q to be rejected", annotation, value)
+		}
+	}
+}
+
+type fakeS3Bucket struct {
+	// objects holds the version IDs of each key
+	objects map[string][]string
+	tags    map[string]string
+	policy  string
+	locked  bool
+}
+
+// fakeS3 serves the S3 requests made to deprovision the
+// buckets of a profile, with the path-style addressing.
+type fakeS3 struct {
+	mutex   sync.Mutex
+	buckets map[string]*fakeS3Bucket
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	name, key := parts[0], ""
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+
+	bucket, ok := s.buckets[name]
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	query := r.URL.Query()
+	_, tagging := query["tagging"]
+	_, objectLock := query["object-lock"]
+	_, versions := query["versions"]
+	_, policy := query["policy"]
+
+	switch {
+	case tagging && r.Method == http.MethodGet:
+		if len(bucket.tags) == 0 {
+			writeS3Error(w, http.StatusNotFound, "NoSuchTagSet")
+			return
+		}
+
+		fmt.Fprint(w, "<Tagging><TagSet>")
+		for key, value := range bucket.tags {
+			fmt.Fprintf(w, "<Tag><Key>%s</Key><Value>%s</Value></Tag>", key, value)
+		}
+		fmt.Fprint(w, "</TagSet></Tagging>")
+	case objectLock && r.Method == http.MethodGet:
+		if !bucket.locked {
+			writeS3Error(w, http.StatusNotFound, "ObjectLockConfigurationNotFoundError")
+			return
+		}
+
+		fmt.Fprint(w, "<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled></ObjectLockConfiguration>")
+	case versions && r.Method == http.MethodGet:
+		keys := make([]string, 0, len(bucket.objects))
+		for key := range bucket.objects {
+			if strings.HasPrefix(key, query.Get("prefix")) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(w, "<ListVersionsResult><Name>%s</Name><IsTruncated>false</IsTruncated>", name)
+		for _, key := range keys {
+			for _, versionID := range bucket.objects[key] {
+				fmt.Fprintf(w, "<Version><Key>%s</Key><VersionId>%s</VersionId></Version>", key, versionID)
+			}
+		}
+		fmt.Fprint(w, "</ListVersionsResult>")
+	case policy && r.Method == http.MethodGet:
+		if bucket.policy == "" {
+			writeS3Error(w, http.StatusNotFound, "NoSuchBucketPolicy")
+			return
+		}
+
+		fmt.Fprint(w, bucket.policy)
+	case policy && r.Method == http.MethodPut:
+		data, _ := ioutil.ReadAll(r.Body)
+		bucket.policy = string(data)
+		w.WriteHeader(http.StatusNoContent)
+	case policy && r.Method == http.MethodDelete:
+		bucket.policy = ""
+		w.WriteHeader(http.StatusNoContent)
+	case key != "" && r.Method == http.MethodDelete:
+		versionIDs := make([]string, 0)
+		for _, versionID := range bucket.objects[key] {
+			if versionID != query.Get("versionId") && query.Get("versionId") != "" {
+				versionIDs = append(versionIDs, versionID)
+			}
+		}
+
+		if len(versionIDs) == 0 {
+			delete(bucket.objects, key)
+		} else {
+			bucket.objects[key] = versionIDs
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case key == "" && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case key == "" && r.Method == http.MethodDelete:
+		if len(bucket.objects) > 0 {
+			writeS3Error(w, http.StatusConflict, "BucketNotEmpty")
+			return
+		}
+
+		delete(s.buckets, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func newTestMinIO(buckets map[string]*fakeS3Bucket) (*MinIOStruct, func()) {
+	server := httptest.NewServer(&fakeS3{buckets: buckets})
+	conf := MinIOConfiguration{
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		Region:          "us-east-1",
+		PathStyle:       true,
+	}
+
+	return &MinIOStruct{
+		Instances: []ObjectStorageInstance{{Name: "minio_standard", Type: ObjectStorageMinIO}},
+		clients: newMinIOClientCache(func(ObjectStorageInstance) (*MinIOConfiguration, error) {
+			current := conf
+			return &current, nil
+		}, time.Hour),
+		breaker: newMinIOCircuitBreaker(0, 0),
+	}, server.Close
+}
+
+func newTestSharedPolicy(t *testing.T, profileNames ...string) string {
+	policy := bucketPolicy{Version: "2012-10-17"}
+	for _, profileName := range profileNames {
+		statement, err := sharedPolicyStatement(minioBucket{name: SHARED_BUCKET, prefixes: []minioPrefix{{name: profileName + "/"}}}, profileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		policy.Statement = append(policy.Statement, statement)
+	}
+
+	data, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func newTestBuckets(t *testing.T) map[string]*fakeS3Bucket {
+	return map[string]*fakeS3Bucket{
+		"alice": {
+			objects: map[string][]string{"data.csv": {"v2", "v1"}, "tmp/run.log": {"v1"}},
+			tags:    map[string]string{MinIOProfileTag: "alice"},
+		},
+		SHARED_BUCKET: {
+			objects: map[string][]string{"alice/.hold": {"null"}, "alice/report.pdf": {"v1"}, "bob/.hold": {"null"}},
+			policy:  newTestSharedPolicy(t, "alice", "bob"),
+		},
+	}
+}
+
+func sortedKeys(objects map[string][]string) []string {
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Tests that only the placeholders of the profile are removed when its
+// data is retained, and the statement of the profile is kept
+func TestDeleteBucketsForProfile_retain(t *testing.T) {
+	buckets := newTestBuckets(t)
+	policy := buckets[SHARED_BUCKET].policy
+	m, stop := newTestMinIO(buckets)
+	defer stop()
+
+	if err := m.DeleteBucketsForProfile(MinIOProfile{Name: "alice", Owner: "alice@example.com"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := sortedKeys(buckets["alice"].objects); !reflect.DeepEqual(keys, []string{"data.csv", "tmp/run.log"}) {
+		t.Errorf("Expected the bucket of the profile to be retained, got %v", keys)
+	}
+
+	if keys := sortedKeys(buckets[SHARED_BUCKET].objects); !reflect.DeepEqual(keys, []string{"alice/report.pdf", "bob/.hold"}) {
+		t.Errorf("Expected only the placeholder of the profile to be removed, got %v", keys)
+	}
+
+	if buckets[SHARED_BUCKET].policy != policy {
+		t.Errorf("Expected the policy to be kept, got %s", buckets[SHARED_BUCKET].policy)
+	}
+}
+
+// Tests that every version of the objects of the profile is removed
+// with its bucket, and that the others profiles are left alone
+func TestDeleteBucketsForProfile_purge(t *testing.T) {
+	buckets := newTestBuckets(t)
+	m, stop := newTestMinIO(buckets)
+	defer stop()
+
+	if err := m.DeleteBucketsForProfile(MinIOProfile{Name: "alice", Owner: "alice@example.com"}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := buckets["alice"]; ok {
+		t.Errorf("Expected the bucket of the profile to be removed, got %v", buckets["alice"].objects)
+	}
+
+	if keys := sortedKeys(buckets[SHARED_BUCKET].objects); !reflect.DeepEqual(keys, []string{"bob/.hold"}) {
+		t.Errorf("Expected the folder of the profile to be emptied, got %v", keys)
+	}
+
+	if buckets[SHARED_BUCKET].policy != newTestSharedPolicy(t, "bob") {
+		t.Errorf("Expected only the statement of the profile to be removed, got %s", buckets[SHARED_BUCKET].policy)
+	}
+
+	// Purging again finds nothing left to remove
+	if err := m.DeleteBucketsForProfile(MinIOProfile{Name: "alice", Owner: "alice@example.com"}, true); err != nil {
+		t.Errorf("Expected the purge to be idempotent, got %v", err)
+	}
+}
+
+func TestDeleteBucketsForProfile_locked(t *testing.T) {
+	buckets := newTestBuckets(t)
+	buckets["alice"].locked = true
+	m, stop := newTestMinIO(buckets)
+	defer stop()
+
+	err := m.DeleteBucketsForProfile(MinIOProfile{Name: "alice", Owner: "alice@example.com"}, true)
+	if !errors.Is(err, ErrMinIOBucketLocked) {
+		t.Fatalf("Expected the locked bucket error, got %v", err)
+	}
+
+	if len(buckets["alice"].objects) != 2 {
+		t.Errorf("Expected the locked bucket to be kept, got %v", buckets["alice"].objects)
+	}
+}
+
+func TestDeleteBucketsForProfile_otherProfile(t *testing.T) {
+	buckets := newTestBuckets(t)
+	buckets["alice"].tags[MinIOProfileTag] = "bob"
+	m, stop := newTestMinIO(buckets)
+	defer stop()
+
+	if err := m.DeleteBucketsForProfile(MinIOProfile{Name: "alice", Owner: "alice@example.com"}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := buckets["alice"]; !ok || len(buckets["alice"].objects) != 2 {
+		t.Error("Expected the bucket of another profile to be kept")
+	}
+}
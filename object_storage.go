@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Types of the object storage instances.
+const (
+	// ObjectStorageMinIO instances support the MinIO admin API, used for the quotas.
+	ObjectStorageMinIO = "minio"
+	// ObjectStorageS3 instances only support the S3 API, such as Ceph RGW.
+	ObjectStorageS3 = "s3"
+)
+
+// ObjectStorageProvisioner provisions the buckets of the Profiles
+// in the object storage instances.
+type ObjectStorageProvisioner interface {
+	// CreateBucketsForProfile creates or updates the buckets of the
+	// profile, and returns the outcome in each instance.
+	CreateBucketsForProfile(profile MinIOProfile) MinIOResults
+	// DeleteBucketsForProfile removes the buckets of a deleted profile,
+	// retaining its data unless purge is set.
+	DeleteBucketsForProfile(profile MinIOProfile, purge bool) error
+}
+
+// ObjectStorageInstance is an S3-compatible object storage
+// instance the buckets of the Profiles are provisioned in.
+type ObjectStorageInstance struct {
+	Name string `json:"name"`
+	// Type of the instance, minio by default.
+	Type string `json:"type,omitempty"`
+
+	// Endpoint and UseSSL are read from Vault with the Vault
+	// credentials, unless they're set.
+	Endpoint string `json:"endpoint,omitempty"`
+	UseSSL   *bool  `json:"useSSL,omitempty"`
+	// Region of the instance, us-east-1 by default.
+	Region string `json:"region,omitempty"`
+	// PathStyle sends the requests to endpoint/bucket rather than
+	// bucket.endpoint, which most S3-compatible stores need.
+	PathStyle bool `json:"pathStyle,omitempty"`
+
+	Credentials ObjectStorageCredentials `json:"credentials,omitempty"`
+
+	// Layout of the buckets of the profiles in the instance.
+	Layout *MinIOBucketLayout `json:"layout,omitempty"`
+}
+
+// ObjectStorageCredentials is where the credentials of an instance are read
+// from. Only one of the sources can be set, Vault is used when none is.
+type ObjectStorageCredentials struct {
+	// Vault is the mount of the Vault MinIO plugin configured for the
+	// instance, the name of the instance by default. The Profiles get
+	// a role of the plugin besides the buckets.
+	Vault string `json:"vault,omitempty"`
+	// Secret is the namespace/name key of a Secret holding
+	// the accessKeyId and secretAccessKey keys.
+	Secret string `json:"secret,omitempty"`
+	// Env is the prefix of the <prefix>_ACCESS_KEY_ID and
+	// <prefix>_SECRET_ACCESS_KEY environment variables.
+	Env string `json:"env,omitempty"`
+}
+
+// vaultMount returns the mount of the Vault MinIO plugin of the instance,
+// or an empty string when its credentials aren't read from Vault.
+func (i ObjectStorageInstance) vaultMount() string {
+	if i.Credentials.Secret != "" || i.Credentials.Env != "" {
+		return ""
+	}
+
+	if i.Credentials.Vault != "" {
+		return i.Credentials.Vault
+	}
+
+	return i.Name
+}
+
+func (i ObjectStorageInstance) validate() error {
+	if i.Name == "" {
+		return fmt.Errorf("instance has no name")
+	}
+
+	if i.Type != ObjectStorageMinIO && i.Type != ObjectStorageS3 {
+		return fmt.Errorf("instance %q: unknown type %q", i.Name, i.Type)
+	}
+
+	sources := 0
+	for _, source := range []string{i.Credentials.Vault, i.Credentials.Secret, i.Credentials.Env} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("instance %q: more than one source of credentials", i.Name)
+	}
+
+	if i.Credentials.Secret != "" {
+		parts := strings.Split(i.Credentials.Secret, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("instance %q: invalid secret %q, expected namespace/name", i.Name, i.Credentials.Secret)
+		}
+	}
+
+	if i.vaultMount() == "" && i.Endpoint == "" {
+		return fmt.Errorf("instance %q: an endpoint is needed without Vault credentials", i.Name)
+	}
+
+	return nil
+}
+
+// NewMinIOInstances returns the instances of the MinIO instances configured
+// in Vault, as given by -minio-instances.
+func NewMinIOInstances(names []string) []ObjectStorageInstance {
+	instances := make([]ObjectStorageInstance, 0, len(names))
+	for _, name := range names {
+		instances = append(instances, ObjectStorageInstance{
+			Name: name,
+			Type: ObjectStorageMinIO,
+		})
+	}
+
+	return instances
+}
+
+// LoadObjectStorageInstances reads the object storage instances,
+// a YAML or JSON list of instances.
+func LoadObjectStorageInstances(filename string) ([]ObjectStorageInstance, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	instances := make([]ObjectStorageInstance, 0)
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(&instances); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	names := make([]string, 0, len(instances))
+	for i := range instances {
+		if instances[i].Type == "" {
+			instances[i].Type = ObjectStorageMinIO
+		}
+
+		if err := instances[i].validate(); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+
+		if StringArrayContains(names, instances[i].Name) {
+			return nil, fmt.Errorf("%s: instance %q is declared more than once", filename, instances[i].Name)
+		}
+		names = append(names, instances[i].Name)
+
+		if instances[i].Layout != nil {
+			data := profileTemplateData{ProfileName: "profile", Owner: "owner@example.com"}
+			if _, err := instances[i].Layout.render(data); err != nil {
+				return nil, fmt.Errorf("%s: instance %q: %v", filename, instances[i].Name, err)
+			}
+		}
+	}
+
+	return instances, nil
+}
+
+// VaultMinIOMounts returns the mounts of the Vault MinIO plugin of the
+// instances whose credentials are read from Vault.
+func VaultMinIOMounts(instances []ObjectStorageInstance) []string {
+	mounts := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		if mount := instance.vaultMount(); mount != "" {
+			mounts = appendUnique(mounts, mount)
+		}
+	}
+
+	return mounts
+}
+
+// objectStorageCredentials reads the configuration of the
+// instance from the source of its credentials.
+type objectStorageCredentials struct {
+	vault   VaultConfigurer
+	secrets v1listers.SecretLister
+}
+
+func (c *objectStorageCredentials) configuration(instance ObjectStorageInstance) (*MinIOConfiguration, error) {
+	conf := &MinIOConfiguration{}
+
+	switch {
+	case instance.Credentials.Secret != "":
+		namespace, name, err := cache.SplitMetaNamespaceKey(instance.Credentials.Secret)
+		if err != nil {
+			return nil, err
+		}
+
+		secret, err := c.secrets.Secrets(namespace).Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("secret %q: %v", instance.Credentials.Secret, err)
+		}
+
+		conf.AccessKeyID = string(secret.Data["accessKeyId"])
+		conf.SecretAccessKey = string(secret.Data["secretAccessKey"])
+	case instance.Credentials.Env != "":
+		conf.AccessKeyID = os.Getenv(instance.Credentials.Env + "_ACCESS_KEY_ID")
+		conf.SecretAccessKey = os.Getenv(instance.Credentials.Env + "_SECRET_ACCESS_KEY")
+	default:
+		if c.vault == nil {
+			return nil, fmt.Errorf("instance %q reads its credentials from Vault, which isn't configured", instance.Name)
+		}
+
+		var err error
+		if conf, err = c.vault.GetMinIOConfiguration(instance.vaultMount()); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.AccessKeyID == "" || conf.SecretAccessKey == "" {
+		return nil, fmt.Errorf("instance %q has no credentials", instance.Name)
+	}
+
+	if instance.Endpoint != "" {
+		conf.Endpoint = instance.Endpoint
+	}
+	if instance.UseSSL != nil {
+		conf.UseSSL = *instance.UseSSL
+	}
+	conf.Region = instance.Region
+	conf.PathStyle = instance.PathStyle
+
+	return conf, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestObjectStorageInstanceValidate(t *testing.T) {
+	tests := map[string]struct {
+		instance ObjectStorageInstance
+		valid    bool
+	}{
+		"vault": {
+			instance: ObjectStorageInstance{Name: "minio_standard", Type: ObjectStorageMinIO},
+			valid:    true,
+		},
+		"secret": {
+			instance: ObjectStorageInstance{
+				Name:        "ceph",
+				Type:        ObjectStorageS3,
+				Endpoint:    "ceph.example.com",
+				Credentials: ObjectStorageCredentials{Secret: "daaas/ceph"},
+			},
+			valid: true,
+		},
+		"env": {
+			instance: ObjectStorageInstance{
+				Name:        "ceph",
+				Type:        ObjectStorageS3,
+				Endpoint:    "ceph.example.com",
+				Credentials: ObjectStorageCredentials{Env: "CEPH"},
+			},
+			valid: true,
+		},
+		"no name": {
+			instance: ObjectStorageInstance{Type: ObjectStorageMinIO},
+		},
+		"unknown type": {
+			instance: ObjectStorageInstance{Name: "gcs", Type: "gcs"},
+		},
+		"no type": {
+			instance: ObjectStorageInstance{Name: "minio_standard"},
+		},
+		"multiple sources": {
+			instance: ObjectStorageInstance{
+				Name:        "minio_standard",
+				Type:        ObjectStorageMinIO,
+				Endpoint:    "minio.example.com",
+				Credentials: ObjectStorageCredentials{Vault: "minio_standard", Env: "MINIO"},
+			},
+		},
+		"invalid secret": {
+			instance: ObjectStorageInstance{
+				Name:        "ceph",
+				Type:        ObjectStorageS3,
+				Endpoint:    "ceph.example.com",
+				Credentials: ObjectStorageCredentials{Secret: "ceph"},
+			},
+		},
+		"secret without name": {
+			instance: ObjectStorageInstance{
+				Name:        "ceph",
+				Type:        ObjectStorageS3,
+				Endpoint:    "ceph.example.com",
+				Credentials: ObjectStorageCredentials{Secret: "daaas/"},
+			},
+		},
+		"no endpoint": {
+			instance: ObjectStorageInstance{
+				Name:        "ceph",
+				Type:        ObjectStorageS3,
+				Credentials: ObjectStorageCredentials{Env: "CEPH"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		err := test.instance.validate()
+		if test.valid && err != nil {
+			t.Errorf("%s: expected the instance to be valid, got %v", name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: expected the instance to be rejected", name)
+		}
+	}
+}
+
+func writeTestFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "object-storage-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+
+	return file.Name()
+}
+
+func TestLoadObjectStorageInstances(t *testing.T) {
+	filename := writeTestFile(t, `
+- name: minio_standard
+- name: ceph
+  type: s3
+  endpoint: ceph.example.com
+  pathStyle: true
+  credentials:
+    secret: daaas/ceph
+  layout:
+    buckets:
+    - name: "{{ .ProfileName }}"
+      owned: true
+`)
+	defer os.Remove(filename)
+
+	instances, err := LoadObjectStorageInstances(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(instances) != 2 {
+		t.Fatalf("Expected 2 instances, got %v", instances)
+	}
+
+	if instances[0].Type != ObjectStorageMinIO {
+		t.Errorf("Expected the minio type by default, got %q", instances[0].Type)
+	}
+
+	if instances[1].Type != ObjectStorageS3 || !instances[1].PathStyle || instances[1].Layout == nil || len(instances[1].Layout.Buckets) != 1 {
+		t.Errorf("Unexpected instance %+v", instances[1])
+	}
+
+	if mounts := VaultMinIOMounts(instances); !reflect.DeepEqual(mounts, []string{"minio_standard"}) {
+		t.Errorf("Expected the Vault mount of minio_standard only, got %v", mounts)
+	}
+}
+
+func TestLoadObjectStorageInstances_invalid(t *testing.T) {
+	invalidFiles := map[string]string{
+		"not a list": "name: minio_standard\n",
+		"duplicate":  "- name: minio_standard\n- name: minio_standard\n",
+		"bad type":   "- name: minio_standard\n  type: gcs\n",
+		"multiple sources": `
+- name: minio_standard
+  endpoint: minio.example.com
+  credentials:
+    secret: daaas/minio
+    env: MINIO
+`,
+		"missing endpoint": "- name: ceph\n  type: s3\n  credentials:\n    env: CEPH\n",
+		"invalid layout": `
+- name: minio_standard
+  layout:
+    buckets:
+    - name: "{{ .Unknown }}"
+`,
+	}
+
+	for name, content := range invalidFiles {
+		filename := writeTestFile(t, content)
+		if _, err := LoadObjectStorageInstances(filename); err == nil {
+			t.Errorf("Expected the %s file to be rejected", name)
+		}
+		os.Remove(filename)
+	}
+
+	if _, err := LoadObjectStorageInstances("/nonexistent/instances.yaml"); err == nil {
+		t.Error("Expected a missing file to be rejected")
+	}
+}
@@ -45,7 +45,10 @@ type Plugin struct {
 type ProfileCondition struct {
 	Type    string `json:"type,omitempty"`
 	Status  string `json:"status,omitempty" description:"status of the condition, one of True, False, Unknown"`
+	Reason  string `json:"reason,omitempty" description:"one-word CamelCase reason for the condition's last transition"`
 	Message string `json:"message,omitempty"`
+
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty" description:"last time the condition transitioned from one status to another"`
 }
 
 // ProfileSpec defines the desired state of Profile
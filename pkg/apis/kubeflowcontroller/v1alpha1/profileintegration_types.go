@@ -0,0 +1,97 @@
+/*
+Copyright 2020 Statistics Canada
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProfileIntegrationSpec defines the resources stamped out for every Profile
+type ProfileIntegrationSpec struct {
+	// RoleBindings to create for every Profile.
+	// +optional
+	RoleBindings []ProfileIntegrationRoleBinding `json:"roleBindings,omitempty"`
+}
+
+// ProfileIntegrationRoleBinding is the template of a RoleBinding.
+// Name, Namespace and the subject names are Go templates rendered
+// with the name of the Profile ({{ .ProfileName }}) and its owner
+// ({{ .Owner }}).
+type ProfileIntegrationRoleBinding struct {
+	// Name of the RoleBinding.
+	Name string `json:"name"`
+
+	// Namespace of the RoleBinding. Defaults to the namespace of the Profile.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// RoleRef is the Role or ClusterRole to bind.
+	RoleRef rbacv1.RoleRef `json:"roleRef"`
+
+	// Subjects bound to the role.
+	// +optional
+	Subjects []ProfileIntegrationSubject `json:"subjects,omitempty"`
+}
+
+// ProfileIntegrationSubject is the template of a RoleBinding subject
+type ProfileIntegrationSubject struct {
+	// Owner binds the owner of the Profile. The other fields are ignored.
+	// +optional
+	Owner bool `json:"owner,omitempty"`
+
+	// Kind of the subject: User, Group or ServiceAccount.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// APIGroup of the subject.
+	// +optional
+	APIGroup string `json:"apiGroup,omitempty"`
+
+	// Name of the subject.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Namespace of a ServiceAccount subject. Defaults to the namespace of the Profile.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ProfileIntegration declares the resources an integration (Pachyderm,
+// Seldon, Argo...) needs for every Profile
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:path=profileintegrations,scope=Cluster
+type ProfileIntegration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProfileIntegrationSpec `json:"spec,omitempty"`
+}
+
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ProfileIntegrationList contains a list of ProfileIntegration
+type ProfileIntegrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProfileIntegration `json:"items"`
+}
@@ -167,3 +167,124 @@ func (in *PodDefaultStatus) DeepCopy() *PodDefaultStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileIntegration) DeepCopyInto(out *ProfileIntegration) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileIntegration.
+func (in *ProfileIntegration) DeepCopy() *ProfileIntegration {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileIntegration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProfileIntegration) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileIntegrationList) DeepCopyInto(out *ProfileIntegrationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ProfileIntegration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileIntegrationList.
+func (in *ProfileIntegrationList) DeepCopy() *ProfileIntegrationList {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileIntegrationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProfileIntegrationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileIntegrationRoleBinding) DeepCopyInto(out *ProfileIntegrationRoleBinding) {
+	*out = *in
+	out.RoleRef = in.RoleRef
+	if in.Subjects != nil {
+		in, out := &in.Subjects, &out.Subjects
+		*out = make([]ProfileIntegrationSubject, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileIntegrationRoleBinding.
+func (in *ProfileIntegrationRoleBinding) DeepCopy() *ProfileIntegrationRoleBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileIntegrationRoleBinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileIntegrationSpec) DeepCopyInto(out *ProfileIntegrationSpec) {
+	*out = *in
+	if in.RoleBindings != nil {
+		in, out := &in.RoleBindings, &out.RoleBindings
+		*out = make([]ProfileIntegrationRoleBinding, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileIntegrationSpec.
+func (in *ProfileIntegrationSpec) DeepCopy() *ProfileIntegrationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileIntegrationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProfileIntegrationSubject) DeepCopyInto(out *ProfileIntegrationSubject) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProfileIntegrationSubject.
+func (in *ProfileIntegrationSubject) DeepCopy() *ProfileIntegrationSubject {
+	if in == nil {
+		return nil
+	}
+	out := new(ProfileIntegrationSubject)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -32,6 +32,10 @@ func (c *FakeKubeflowV1alpha1) PodDefaults(namespace string) v1alpha1.PodDefault
 	return &FakePodDefaults{c, namespace}
 }
 
+func (c *FakeKubeflowV1alpha1) ProfileIntegrations() v1alpha1.ProfileIntegrationInterface {
+	return &FakeProfileIntegrations{c}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeKubeflowV1alpha1) RESTClient() rest.Interface {
@@ -27,6 +27,7 @@ import (
 type KubeflowV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	PodDefaultsGetter
+	ProfileIntegrationsGetter
 }
 
 // KubeflowV1alpha1Client is used to interact with features provided by the kubeflow.org group.
@@ -38,6 +39,10 @@ func (c *KubeflowV1alpha1Client) PodDefaults(namespace string) PodDefaultInterfa
 	return newPodDefaults(c, namespace)
 }
 
+func (c *KubeflowV1alpha1Client) ProfileIntegrations() ProfileIntegrationInterface {
+	return newProfileIntegrations(c)
+}
+
 // NewForConfig creates a new KubeflowV1alpha1Client for the given config.
 func NewForConfig(c *rest.Config) (*KubeflowV1alpha1Client, error) {
 	config := *c
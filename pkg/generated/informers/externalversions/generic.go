@@ -62,6 +62,8 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 		// Group=kubeflow.org, Version=v1alpha1
 	case v1alpha1.SchemeGroupVersion.WithResource("poddefaults"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Kubeflow().V1alpha1().PodDefaults().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("profileintegrations"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Kubeflow().V1alpha1().ProfileIntegrations().Informer()}, nil
 
 	}
 
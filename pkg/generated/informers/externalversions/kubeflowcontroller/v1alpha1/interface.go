@@ -26,6 +26,8 @@ import (
 type Interface interface {
 	// PodDefaults returns a PodDefaultInformer.
 	PodDefaults() PodDefaultInformer
+	// ProfileIntegrations returns a ProfileIntegrationInformer.
+	ProfileIntegrations() ProfileIntegrationInformer
 }
 
 type version struct {
@@ -43,3 +45,8 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 func (v *version) PodDefaults() PodDefaultInformer {
 	return &podDefaultInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// ProfileIntegrations returns a ProfileIntegrationInformer.
+func (v *version) ProfileIntegrations() ProfileIntegrationInformer {
+	return &profileIntegrationInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
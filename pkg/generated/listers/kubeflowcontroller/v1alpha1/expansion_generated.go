@@ -25,3 +25,7 @@ type PodDefaultListerExpansion interface{}
 // PodDefaultNamespaceListerExpansion allows custom methods to be added to
 // PodDefaultNamespaceLister.
 type PodDefaultNamespaceListerExpansion interface{}
+
+// ProfileIntegrationListerExpansion allows custom methods to be added to
+// ProfileIntegrationLister.
+type ProfileIntegrationListerExpansion interface{}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	kubeflowv1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1"
+)
+
+const (
+	// RegistryCredentialsAnnotation selects, as a comma-separated list of names,
+	// the registry credentials copied into the namespace of a Profile.
+	RegistryCredentialsAnnotation = "kubeflow-controller.statcan.gc.ca/registry-credentials"
+	// RegistryCredentialLabel is set on the image pull secrets
+	// to the name of their registry credential.
+	RegistryCredentialLabel = "kubeflow-controller.statcan.gc.ca/registry-credential"
+
+	// LegacyRegistryCredential is the name of the registry credential
+	// given by -image-pull-secret or -image-pull-secret-source.
+	LegacyRegistryCredential = "image-pull-secret"
+)
+
+// RegistryCredential is a docker config copied into the namespace of the
+// Profiles as an image pull secret of the same name.
+type RegistryCredential struct {
+	Name string
+	// Source is the namespace/name key of the Secret holding the docker config.
+	Source string
+	// DockerConfigJSON is used when no Source is given.
+	DockerConfigJSON []byte
+}
+
+// ParseRegistryCredentials parses a comma-separated list of
+// name=namespace/secret registry credentials.
+func ParseRegistryCredentials(value string) ([]RegistryCredential, error) {
+	credentials := make([]RegistryCredential, 0)
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || len(strings.Split(parts[1], "/")) != 2 {
+			return nil, fmt.Errorf("invalid registry credential %q, expected name=namespace/secret", entry)
+		}
+
+		credentials = append(credentials, RegistryCredential{
+			Name:   parts[0],
+			Source: parts[1],
+		})
+	}
+
+	return credentials, nil
+}
+
+// registryCredentialsForProfile returns the registry credentials selected
+// by the annotation of the Profile, or the default ones.
+func (c *Controller) registryCredentialsForProfile(profile *kubeflowv1.Profile) ([]RegistryCredential, error) {
+	names := c.defaultRegistryCredentials
+	if value, ok := profile.Annotations[RegistryCredentialsAnnotation]; ok {
+		names = splitList(value)
+	}
+
+	credentials := make([]RegistryCredential, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, credential := range c.registryCredentials {
+			if credential.Name == name {
+				credentials = append(credentials, credential)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown registry credential %q", name)
+		}
+	}
+
+	return credentials, nil
+}
+
+// doImagePullSecrets creates or updates the image pull secrets of the registry
+// credentials selected by the Profile, and attaches them to the configured
+// ServiceAccounts. Image pull secrets no longer selected are removed.
+func (c *Controller) doImagePullSecrets(profile *kubeflowv1.Profile) error {
+	credentials, err := c.registryCredentialsForProfile(profile)
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, 0)
+	desired := make([]string, 0, len(credentials))
+	for _, credential := range credentials {
+		desired = append(desired, credential.Name)
+
+		if err := c.doImagePullSecret(profile, credential); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", credential.Name, err))
+		}
+	}
+
+	// Don't detach anything if the desired state is incomplete
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+
+	managed, err := c.removeStaleImagePullSecrets(profile, desired)
+	if err != nil {
+		return err
+	}
+
+	for _, serviceAccountName := range c.imagePullSecretServiceAccounts {
+		if err := c.doServiceAccountImagePullSecrets(profile, serviceAccountName, desired, managed); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", serviceAccountName, err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// doImagePullSecret creates or updates the image pull secret of a
+// registry credential in the namespace of the Profile.
+func (c *Controller) doImagePullSecret(profile *kubeflowv1.Profile, credential RegistryCredential) error {
+	dockerConfigJSON, err := c.getDockerConfigJSON(credential)
+	if err != nil {
+		return err
+	}
+
+	expected := newImagePullSecret(profile, credential.Name, dockerConfigJSON)
+
+	secret, err := c.secretsLister.Secrets(profile.Name).Get(credential.Name)
+	// If the resource doesn't exist, we'll create it
+	if errors.IsNotFound(err) {
+		secret, err = c.kubeclientset.CoreV1().Secrets(profile.Name).Create(context.TODO(), expected, metav1.CreateOptions{})
+	}
+
+	// If an error occurs during Get/Create, we'll requeue the item so we can
+	// attempt processing again later. This could have been caused by a
+	// temporary network failure, or any other transient reason.
+	if err != nil {
+		return err
+	}
+
+	// If the Secret is not controlled by this Profile resource, we should log
+	// a warning to the event recorder and return error msg.
+	if !metav1.IsControlledBy(secret, profile) {
+		msg := fmt.Sprintf(MessageResourceExists, secret.Name)
+		c.recorder.Event(profile, v1.EventTypeWarning, ErrResourceExists, msg)
+		return fmt.Errorf(msg)
+	}
+
+	// The credential was rotated, update the copy in the namespace
+	if !bytes.Equal(secret.Data[v1.DockerConfigJsonKey], dockerConfigJSON) || secret.Labels[RegistryCredentialLabel] != credential.Name {
+		klog.Infof("updating the image pull secret %q of profile %q", credential.Name, profile.Name)
+
+		secretCopy := secret.DeepCopy()
+		secretCopy.Data = expected.Data
+		if secretCopy.Labels == nil {
+			secretCopy.Labels = make(map[string]string)
+		}
+		secretCopy.Labels[RegistryCredentialLabel] = credential.Name
+
+		_, err = c.kubeclientset.CoreV1().Secrets(profile.Name).Update(context.TODO(), secretCopy, metav1.UpdateOptions{})
+	}
+
+	return err
+}
+
+// removeStaleImagePullSecrets deletes the image pull secrets of the Profile
+// that aren't desired anymore. It returns the names of all the image pull
+// secrets managed by the controller, desired or not.
+func (c *Controller) removeStaleImagePullSecrets(profile *kubeflowv1.Profile, desired []string) ([]string, error) {
+	managed := []string{LegacyRegistryCredential}
+	for _, credential := range c.registryCredentials {
+		managed = append(managed, credential.Name)
+	}
+
+	requirement, err := labels.NewRequirement(RegistryCredentialLabel, selection.Exists, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := c.secretsLister.Secrets(profile.Name).List(labels.NewSelector().Add(*requirement))
+	if err != nil {
+		return nil, err
+	}
+
+	errs := make([]error, 0)
+	for _, secret := range secrets {
+		if !metav1.IsControlledBy(secret, profile) {
+			continue
+		}
+
+		managed = append(managed, secret.Name)
+		if StringArrayContains(desired, secret.Name) {
+			continue
+		}
+
+		klog.Infof("removing the image pull secret %q of profile %q", secret.Name, profile.Name)
+		err := c.kubeclientset.CoreV1().Secrets(profile.Name).Delete(context.TODO(), secret.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+
+	return managed, utilerrors.NewAggregate(errs)
+}
+
+// doServiceAccountImagePullSecrets attaches the desired image pull secrets to
+// the ServiceAccount, and detaches the managed ones which aren't desired.
+func (c *Controller) doServiceAccountImagePullSecrets(profile *kubeflowv1.Profile, serviceAccountName string, desired, managed []string) error {
+	serviceAccount, err := c.serviceAccountLister.ServiceAccounts(profile.Name).Get(serviceAccountName)
+	// If the resource doesn't exist, exit. We'll loop around and try again,
+	// it's likely the Kubeflow profile-controller hasn't created it yet.
+	if err != nil {
+		return err
+	}
+
+	pullSecrets := make([]v1.LocalObjectReference, 0, len(serviceAccount.ImagePullSecrets))
+	attached := make([]string, 0)
+	for _, pullSecret := range serviceAccount.ImagePullSecrets {
+		if StringArrayContains(managed, pullSecret.Name) && !StringArrayContains(desired, pullSecret.Name) {
+			continue
+		}
+
+		pullSecrets = append(pullSecrets, pullSecret)
+		attached = append(attached, pullSecret.Name)
+	}
+
+	missing := make([]string, 0)
+	for _, name := range desired {
+		if !StringArrayContains(attached, name) {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+
+	for _, name := range missing {
+		pullSecrets = append(pullSecrets, v1.LocalObjectReference{
+			Name: name,
+		})
+	}
+
+	if len(missing) == 0 && len(pullSecrets) == len(serviceAccount.ImagePullSecrets) {
+		return nil
+	}
+
+	serviceAccountCopy := serviceAccount.DeepCopy()
+	serviceAccountCopy.ImagePullSecrets = pullSecrets
+
+	_, err = c.kubeclientset.CoreV1().ServiceAccounts(profile.Name).Update(context.TODO(), serviceAccountCopy, metav1.UpdateOptions{})
+	return err
+}
+
+// getDockerConfigJSON returns the docker config of the registry credential,
+// read from its source Secret when one is configured.
+func (c *Controller) getDockerConfigJSON(credential RegistryCredential) ([]byte, error) {
+	if credential.Source == "" {
+		return credential.DockerConfigJSON, nil
+	}
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(credential.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := c.secretsLister.Secrets(namespace).Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("source %q: %v", credential.Source, err)
+	}
+
+	dockerConfigJSON, ok := source.Data[v1.DockerConfigJsonKey]
+	if !ok {
+		return nil, fmt.Errorf("source %q has no %s", credential.Source, v1.DockerConfigJsonKey)
+	}
+
+	return dockerConfigJSON, nil
+}
+
+func newImagePullSecret(profile *kubeflowv1.Profile, name string, dockerConfigJSON []byte) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: profile.Name,
+			Labels: map[string]string{
+				RegistryCredentialLabel: name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(profile, kubeflowv1.SchemeGroupVersion.WithKind("Profile")),
+			},
+		},
+		Type: v1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			v1.DockerConfigJsonKey: dockerConfigJSON,
+		},
+	}
+}
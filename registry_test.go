@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRegistryCredentials(t *testing.T) {
+	credentials, err := ParseRegistryCredentials("artifactory=daaas/artifactory, , dockerhub=daaas/dockerhub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []RegistryCredential{
+		{Name: "artifactory", Source: "daaas/artifactory"},
+		{Name: "dockerhub", Source: "daaas/dockerhub"},
+	}
+	if !reflect.DeepEqual(credentials, expected) {
+		t.Errorf("Unexpected credentials %v", credentials)
+	}
+
+	if credentials, err := ParseRegistryCredentials(""); err != nil || len(credentials) != 0 {
+		t.Errorf("Expected no credentials, got %v and %v", credentials, err)
+	}
+
+	for _, value := range []string{"artifactory", "=daaas/artifactory", "artifactory=artifactory", "artifactory=a/b/c"} {
+		if _, err := ParseRegistryCredentials(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+// Tests that the annotation of the Profile selects its
+// registry credentials in place of the default ones
+func TestRegistryCredentialsForProfile(t *testing.T) {
+	c := &Controller{
+		registryCredentials: []RegistryCredential{
+			{Name: "artifactory", Source: "daaas/artifactory"},
+			{Name: "dockerhub", Source: "daaas/dockerhub"},
+		},
+		defaultRegistryCredentials: []string{"artifactory"},
+	}
+
+	tests := map[string]struct {
+		annotations map[string]string
+		expected    []string
+	}{
+		"default":   {expected: []string{"artifactory"}},
+		"selected":  {annotations: map[string]string{RegistryCredentialsAnnotation: "dockerhub, artifactory"}, expected: []string{"dockerhub", "artifactory"}},
+		"opted out": {annotations: map[string]string{RegistryCredentialsAnnotation: ""}, expected: []string{}},
+	}
+
+	for name, test := range tests {
+		profile := newTestProfile()
+		profile.Annotations = test.annotations
+
+		credentials, err := c.registryCredentialsForProfile(profile)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		names := make([]string, 0, len(credentials))
+		for _, credential := range credentials {
+			names = append(names, credential.Name)
+		}
+
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%s: expected %v, got %v", name, test.expected, names)
+		}
+	}
+
+	profile := newTestProfile()
+	profile.Annotations = map[string]string{RegistryCredentialsAnnotation: "unknown"}
+	if _, err := c.registryCredentialsForProfile(profile); err == nil {
+		t.Error("Expected an error for an unknown registry credential")
+	}
+}
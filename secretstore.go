@@ -0,0 +1,99 @@
+package main
+
+import (
+	"time"
+
+	"k8s.io/klog"
+)
+
+// Backends the secret stores of the Profiles can be provisioned in.
+const (
+	// SecretStoreVault provisions a KV store, policies and roles in HashiCorp Vault.
+	SecretStoreVault = "vault"
+	// SecretStoreNoop provisions nothing, the Profiles only get
+	// the Kubernetes Secrets of their namespace.
+	SecretStoreNoop = "noop"
+)
+
+// SecretStoreProvisioner provisions the secret store of the Profiles
+// and the access of their subjects to it.
+type SecretStoreProvisioner interface {
+	// ProvisionProfile creates or updates the secret store of the profile.
+	ProvisionProfile(profile SecretStoreProfile) error
+	// DeprovisionProfile removes the secret store of a deleted profile,
+	// archiving its secrets unless purge is set.
+	DeprovisionProfile(profileName string, purge bool) error
+	// SweepSubjects removes the identities of the users which haven't been
+	// the owner or a contributor of any profile for the grace period.
+	SweepSubjects(profiles map[string][]string, gracePeriod time.Duration) error
+}
+
+// SecretStoreProfile describes the profile to provision a secret store for
+type SecretStoreProfile struct {
+	Name  string
+	Owner string
+	// Editors can edit the profile, besides its owner.
+	Editors SecretStoreSubjects
+	// Viewers get read-only access to the profile.
+	Viewers SecretStoreSubjects
+
+	// PolicyTier selects the Vault policy template of the profile.
+	// The default tier is used when it is empty.
+	PolicyTier string
+	// KubernetesRole overrides the Vault Kubernetes auth role configuration.
+	KubernetesRole KubernetesRoleConfig
+}
+
+// SecretStoreSubjects are the subjects of the RoleBindings of a profile
+type SecretStoreSubjects struct {
+	Users []string
+	// Groups are the names of the OIDC groups.
+	Groups []string
+	// ServiceAccounts are the namespace/name keys of the ServiceAccounts.
+	ServiceAccounts []string
+}
+
+// NewVaultSecretStore returns a provisioner of the secret stores in Vault.
+func NewVaultSecretStore(vaultConfigurer VaultConfigurer) SecretStoreProvisioner {
+	return &vaultSecretStore{
+		vaultConfigurer: vaultConfigurer,
+	}
+}
+
+type vaultSecretStore struct {
+	vaultConfigurer VaultConfigurer
+}
+
+func (s *vaultSecretStore) ProvisionProfile(profile SecretStoreProfile) error {
+	return s.vaultConfigurer.ConfigVaultForProfile(profile)
+}
+
+func (s *vaultSecretStore) DeprovisionProfile(profileName string, purge bool) error {
+	return s.vaultConfigurer.DeconfigVaultForProfile(profileName, purge)
+}
+
+func (s *vaultSecretStore) SweepSubjects(profiles map[string][]string, gracePeriod time.Duration) error {
+	return s.vaultConfigurer.SweepEntities(profiles, gracePeriod)
+}
+
+// NewNoopSecretStore returns a provisioner which provisions nothing, for
+// the clusters without a secret store such as development clusters.
+func NewNoopSecretStore() SecretStoreProvisioner {
+	return &noopSecretStore{}
+}
+
+type noopSecretStore struct{}
+
+func (s *noopSecretStore) ProvisionProfile(profile SecretStoreProfile) error {
+	klog.V(4).Infof("no secret store to provision for profile %q", profile.Name)
+	return nil
+}
+
+func (s *noopSecretStore) DeprovisionProfile(profileName string, purge bool) error {
+	klog.V(4).Infof("no secret store to remove for profile %q", profileName)
+	return nil
+}
+
+func (s *noopSecretStore) SweepSubjects(profiles map[string][]string, gracePeriod time.Duration) error {
+	return nil
+}
@@ -1,6 +1,12 @@
 package main
 
-import "strings"
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	kubeflowv1 "github.com/StatCan/kubeflow-controller/pkg/apis/kubeflowcontroller/v1"
+)
 
 // StringArrayContains checks if a value is within a string array.
 func StringArrayContains(strings []string, str string) bool {
@@ -56,6 +62,56 @@ func FindMissingStrings(strings, expectedStrings []string) []string {
 	}
 }
 
+// splits a comma-separated list, ignoring the empty values
+func splitList(value string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// appends the value to the array, unless it's already in it
+func appendUnique(strings []string, str string) []string {
+	if StringArrayContains(strings, str) {
+		return strings
+	}
+
+	return append(strings, str)
+}
+
 func cleanName(name string) string {
 	return strings.ReplaceAll(name, "_", "-")
-}
\ No newline at end of file
+}
+
+// profileTemplateData is the data the templates of the resources
+// created for a Profile are rendered with.
+type profileTemplateData struct {
+	ProfileName string
+	Owner       string
+}
+
+func newProfileTemplateData(profile *kubeflowv1.Profile) profileTemplateData {
+	return profileTemplateData{
+		ProfileName: profile.Name,
+		Owner:       profile.Spec.Owner.Name,
+	}
+}
+
+// renderProfileTemplate renders a template with the data of a Profile.
+func renderProfileTemplate(text string, data profileTemplateData) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
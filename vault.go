@@ -3,30 +3,83 @@ package main
 import (
 	"bytes"
 	"fmt"
-	"html/template"
+	"io/ioutil"
+	"os"
 	"path"
+	"reflect"
 	"strings"
+	"text/template"
+	"time"
 
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/token"
 	vault "github.com/hashicorp/vault/api"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog"
 )
 
 // Create a VaultConfigurerStruct that implements the VaultConfigurer
-func NewVaultConfigurer(vc *vault.Client, kubePath, oidcAccessor string, minioInstances []string) *VaultConfigurerStruct {
+func NewVaultConfigurer(vc *vault.Client, kubePath, oidcAccessor string, minioInstances []string, profileNamespace string) *VaultConfigurerStruct {
 	return &VaultConfigurerStruct{
 		Logical:            &LogicalWrapper{vc.Logical()},
 		Mounts:             vc.Sys(),
+		Client:             vc,
 		KubernetesAuthPath: kubePath,
 		OidcAuthAccessor:   oidcAccessor,
 		MinioInstances:     minioInstances,
+		ProfileNamespace:   profileNamespace,
 	}
 }
 
 type VaultConfigurer interface {
-	ConfigVaultForProfile(profileName, ownerName string, users []string) error
+	ConfigVaultForProfile(profile SecretStoreProfile) error
+	DeconfigVaultForProfile(profileName string, purge bool) error
+	SweepEntities(profiles map[string][]string, gracePeriod time.Duration) error
 	GetMinIOConfiguration(profileName string) (*MinIOConfiguration, error)
 }
 
+// KubernetesRoleConfig configures the Kubernetes auth roles of a profile.
+// The zero values leave the defaults of Vault.
+type KubernetesRoleConfig struct {
+	// ServiceAccounts bound to the role of the profile, "*" when empty.
+	ServiceAccounts []string
+	TokenTTL        time.Duration
+	TokenMaxTTL     time.Duration
+	Audience        string
+	// Policies granted by the role of the profile, besides its own policy.
+	Policies []string
+}
+
+// merge returns the configuration overridden by the set values of the
+// configuration of a profile. The policies of both are granted.
+func (c KubernetesRoleConfig) merge(profile KubernetesRoleConfig) KubernetesRoleConfig {
+	merged := c
+	merged.Policies = append([]string{}, c.Policies...)
+
+	if len(profile.ServiceAccounts) > 0 {
+		merged.ServiceAccounts = profile.ServiceAccounts
+	}
+
+	if profile.TokenTTL > 0 {
+		merged.TokenTTL = profile.TokenTTL
+	}
+
+	if profile.TokenMaxTTL > 0 {
+		merged.TokenMaxTTL = profile.TokenMaxTTL
+	}
+
+	if profile.Audience != "" {
+		merged.Audience = profile.Audience
+	}
+
+	for _, policy := range profile.Policies {
+		merged.Policies = appendUnique(merged.Policies, policy)
+	}
+
+	return merged
+}
+
 // Defines a configuration object with the constants used to
 // configure the vault instance
 type VaultConfigurerStruct struct {
@@ -35,6 +88,65 @@ type VaultConfigurerStruct struct {
 	KubernetesAuthPath string
 	OidcAuthAccessor   string
 	MinioInstances     []string
+
+	// Client is cloned to send the requests of a profile
+	// to the Vault Enterprise namespace of the profile.
+	Client *vault.Client
+	// ProfileNamespace is the template of the Vault Enterprise
+	// namespace of a profile, rendered with {{ .ProfileName }}.
+	ProfileNamespace string
+
+	// PolicyTemplatesDir holds a policy template per tier, in <tier>.hcl
+	// files. POLICY_TEMPLATE is used for the standard tier when it's missing.
+	PolicyTemplatesDir string
+	// DefaultPolicyTier is the tier of the profiles which don't select one.
+	DefaultPolicyTier string
+
+	// KubernetesRole is the default configuration of
+	// the Kubernetes auth roles of the profiles.
+	KubernetesRole KubernetesRoleConfig
+
+	// root is the configurer a profile configurer was scoped from.
+	root *VaultConfigurerStruct
+}
+
+// forProfile returns a copy of the configurer whose requests are sent to
+// the Vault namespace of the profile, or the configurer itself when the
+// profiles don't have their own namespace.
+func (vc *VaultConfigurerStruct) forProfile(profileName string) (*VaultConfigurerStruct, error) {
+	if vc.Client == nil || vc.ProfileNamespace == "" {
+		return vc, nil
+	}
+
+	namespace, err := renderProfileTemplate(vc.ProfileNamespace, profileTemplateData{ProfileName: profileName})
+	if err != nil {
+		return nil, fmt.Errorf("vault namespace: %v", err)
+	}
+
+	client, err := vc.Client.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	client.SetToken(vc.Client.Token())
+	client.SetNamespace(namespace)
+
+	scoped := *vc
+	scoped.Logical = &LogicalWrapper{client.Logical()}
+	scoped.Mounts = client.Sys()
+	scoped.root = vc
+	return &scoped, nil
+}
+
+// minio returns the configurer of the MinIO plugin mounts. They live in
+// the namespace of the controller, where GetMinIOConfiguration reads their
+// configuration, even when the profiles have a namespace of their own.
+func (vc *VaultConfigurerStruct) minio() *VaultConfigurerStruct {
+	if vc.root != nil {
+		return vc.root
+	}
+
+	return vc
 }
 
 //go:generate moq -out vault_mocks_test.go . VaultLogicalAPI VaultMountsAPI
@@ -42,6 +154,8 @@ type VaultConfigurerStruct struct {
 type VaultLogicalAPI interface {
 	Read(path string) (*vault.Secret, error)
 	Write(path string, data map[string]interface{}) (*vault.Secret, error)
+	Delete(path string) (*vault.Secret, error)
+	List(path string) (*vault.Secret, error)
 }
 
 //Wrapper struct to allow easy extension of the Vault Api
@@ -57,9 +171,11 @@ func logWarnings(warnings []string) {
 	}
 }
 
-// Wraps the Vault API and outputs warnings if there are any
+// Wraps the Vault API, records metrics and outputs warnings if there are any
 func (l *LogicalWrapper) Read(path string) (*vault.Secret, error) {
+	start := time.Now()
 	secret, err := l.Logical.Read(path)
+	observeVaultRequest("read", start, err)
 
 	if secret != nil {
 		logWarnings(secret.Warnings)
@@ -68,9 +184,37 @@ func (l *LogicalWrapper) Read(path string) (*vault.Secret, error) {
 	return secret, err
 }
 
-// Wraps the Vault API and outputs warnings if there are any
+// Wraps the Vault API, records metrics and outputs warnings if there are any
 func (l *LogicalWrapper) Write(path string, data map[string]interface{}) (*vault.Secret, error) {
+	start := time.Now()
 	secret, err := l.Logical.Write(path, data)
+	observeVaultRequest("write", start, err)
+
+	if secret != nil {
+		logWarnings(secret.Warnings)
+	}
+
+	return secret, err
+}
+
+// Wraps the Vault API, records metrics and outputs warnings if there are any
+func (l *LogicalWrapper) Delete(path string) (*vault.Secret, error) {
+	start := time.Now()
+	secret, err := l.Logical.Delete(path)
+	observeVaultRequest("delete", start, err)
+
+	if secret != nil {
+		logWarnings(secret.Warnings)
+	}
+
+	return secret, err
+}
+
+// Wraps the Vault API, records metrics and outputs warnings if there are any
+func (l *LogicalWrapper) List(path string) (*vault.Secret, error) {
+	start := time.Now()
+	secret, err := l.Logical.List(path)
+	observeVaultRequest("list", start, err)
 
 	if secret != nil {
 		logWarnings(secret.Warnings)
@@ -83,10 +227,41 @@ func (l *LogicalWrapper) Write(path string, data map[string]interface{}) (*vault
 type VaultMountsAPI interface {
 	ListMounts() (map[string]*vault.MountOutput, error)
 	Mount(path string, mountInfo *vault.MountInput) error
+	Unmount(path string) error
+	Remount(from, to string) error
 }
 
 const DEFAULT = "default"
 
+// STANDARD_POLICY_TIER is the tier of the built-in POLICY_TEMPLATE
+const STANDARD_POLICY_TIER = "standard"
+
+// VaultPolicyTierLabel selects the policy tier of a Profile, such as
+// standard, protected-b or read-only. It can be set as an annotation too.
+const VaultPolicyTierLabel = "kubeflow-controller.statcan.gc.ca/vault-policy"
+
+// Annotations of a Profile overriding the configuration of its Kubernetes
+// auth role. The lists are comma-separated and the TTLs are durations.
+const (
+	VaultRoleServiceAccountsAnnotation = "kubeflow-controller.statcan.gc.ca/vault-role-service-accounts"
+	VaultRoleTokenTTLAnnotation        = "kubeflow-controller.statcan.gc.ca/vault-role-token-ttl"
+	VaultRoleTokenMaxTTLAnnotation     = "kubeflow-controller.statcan.gc.ca/vault-role-token-max-ttl"
+	VaultRoleAudienceAnnotation        = "kubeflow-controller.statcan.gc.ca/vault-role-audience"
+	VaultRolePoliciesAnnotation        = "kubeflow-controller.statcan.gc.ca/vault-role-policies"
+)
+
+// Mounts of deleted profiles are moved under this
+// prefix unless they are purged.
+const ARCHIVE_PREFIX = "archive"
+
+// Access levels of the subjects of a profile. The viewer policy, group and
+// roles of a profile are named profile-<name>.viewer, as a profile name
+// can't contain a dot.
+const (
+	EDITOR = "editor"
+	VIEWER = "viewer"
+)
+
 const POLICY_TEMPLATE = `
 #
 # Policy for Kubeflow profile: {{ .ProfileName }}
@@ -107,15 +282,39 @@ path "{{ $instance }}/keys/{{ $profile }}" {
 {{- end }}
 `
 
-func generatePolicy(name string, minioInstances []string) (string, error) {
+// VIEWER_POLICY_TEMPLATE is the built-in template of the policy of
+// the viewers of a profile, used when there's no viewer.hcl template
+const VIEWER_POLICY_TEMPLATE = `
+#
+# Read-only policy for the viewers of Kubeflow profile: {{ .ProfileName }}
+# (policy managed by the custom Kubeflow Profiles controller)
+#
+
+# Grant read access to the KV created for this profile
+path "kv_{{ .ProfileName }}/*" {
+	capabilities = ["read", "list"]
+}
+`
+
+// PolicyTemplateData is the data the policy templates are rendered with
+type PolicyTemplateData struct {
+	// ProfileName is the name of the policy, profile-<name>
+	ProfileName    string
+	Owner          string
+	Namespace      string
+	MinioInstances []string
+}
+
+func generatePolicy(policyTemplate string, data PolicyTemplateData) (string, error) {
+
+	t, err := template.New("policy").Option("missingkey=error").Parse(policyTemplate)
+	if err != nil {
+		return "", err
+	}
 
-	t, _ := template.New("policy").Parse(POLICY_TEMPLATE)
 	w := bytes.NewBufferString("")
 
-	err := t.Execute(w, struct {
-		ProfileName    string
-		MinioInstances []string
-	}{ProfileName: name, MinioInstances: minioInstances})
+	err = t.Execute(w, data)
 
 	if err != nil {
 		return "", err
@@ -124,17 +323,154 @@ func generatePolicy(name string, minioInstances []string) (string, error) {
 	return w.String(), nil
 }
 
-// Writes a policy to Vault
-func (vc *VaultConfigurerStruct) doPolicy(name string) (string, error) {
-	policy, err := generatePolicy(name, vc.MinioInstances)
+// InvalidPolicyError is returned when the policy rendered for a profile
+// is malformed or grants more than the profile should get. Such a policy
+// is never written to Vault.
+type InvalidPolicyError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvalidPolicyError) Error() string {
+	return fmt.Sprintf("invalid policy %q: %s", e.Name, e.Reason)
+}
+
+// validatePolicy parses the policy as HCL and rejects the
+// wildcard paths outside of the KV mount of the profile.
+func validatePolicy(name, policy string) error {
+	file, err := hcl.ParseString(policy)
+	if err != nil {
+		return &InvalidPolicyError{Name: name, Reason: err.Error()}
+	}
+
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return &InvalidPolicyError{Name: name, Reason: "policy is not an HCL object"}
+	}
+
+	kvPrefix := fmt.Sprintf("kv_%s/", name)
+	for _, item := range list.Items {
+		if len(item.Keys) != 2 || item.Keys[0].Token.Text != "path" {
+			return &InvalidPolicyError{Name: name, Reason: fmt.Sprintf("unexpected stanza at %s", item.Pos())}
+		}
+
+		if item.Keys[1].Token.Type != token.STRING {
+			return &InvalidPolicyError{Name: name, Reason: fmt.Sprintf("path at %s is not a string", item.Pos())}
+		}
+
+		policyPath, _ := item.Keys[1].Token.Value().(string)
+		if strings.ContainsAny(policyPath, "*+") && !strings.HasPrefix(policyPath, kvPrefix) {
+			return &InvalidPolicyError{Name: name, Reason: fmt.Sprintf("wildcard path %q outside of %s", policyPath, kvPrefix)}
+		}
+	}
+
+	return nil
+}
+
+// renders the policy and checks it before anything is written to Vault
+func renderPolicy(name, policyTemplate string, data PolicyTemplateData) (string, error) {
+	policy, err := generatePolicy(policyTemplate, data)
+	if err != nil {
+		return "", &InvalidPolicyError{Name: name, Reason: err.Error()}
+	}
+
+	if err := validatePolicy(data.ProfileName, policy); err != nil {
+		if policyErr, ok := err.(*InvalidPolicyError); ok {
+			policyErr.Name = name
+		}
+		return "", err
+	}
+
+	return policy, nil
+}
+
+// reads the <name>.hcl policy template, or returns the
+// built-in template when there's no such file
+func (vc *VaultConfigurerStruct) readPolicyTemplate(name, builtin string) (string, error) {
+	if vc.PolicyTemplatesDir != "" {
+		policyTemplate, err := ioutil.ReadFile(path.Join(vc.PolicyTemplatesDir, path.Base(name)+".hcl"))
+		if err == nil {
+			return string(policyTemplate), nil
+		}
+
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	if builtin == "" {
+		return "", fmt.Errorf("no policy template for %q", name)
+	}
+
+	return builtin, nil
+}
+
+// loads the policy template of the tier
+func (vc *VaultConfigurerStruct) policyTemplate(tier string) (string, error) {
+	if tier == "" {
+		tier = vc.DefaultPolicyTier
+	}
+
+	if tier == "" {
+		tier = STANDARD_POLICY_TIER
+	}
+
+	builtin := ""
+	if tier == STANDARD_POLICY_TIER {
+		builtin = POLICY_TEMPLATE
+	}
+
+	return vc.readPolicyTemplate(tier, builtin)
+}
+
+// Writes the policy of the profile to Vault
+func (vc *VaultConfigurerStruct) doPolicy(name string, profile SecretStoreProfile) (string, error) {
+	policyTemplate, err := vc.policyTemplate(profile.PolicyTier)
+	if err != nil {
+		return name, err
+	}
+
+	policy, err := renderPolicy(name, policyTemplate, PolicyTemplateData{
+		ProfileName:    name,
+		Owner:          profile.Owner,
+		Namespace:      profile.Name,
+		MinioInstances: vc.MinioInstances,
+	})
 	if err != nil {
 		return name, err
 	}
 
+	return name, vc.writePolicy(name, policy)
+}
+
+// Writes the read-only policy of the viewers of the profile to Vault
+func (vc *VaultConfigurerStruct) doViewerPolicy(name string, profile SecretStoreProfile) (string, error) {
+	viewerName := fmt.Sprintf("%s.%s", name, VIEWER)
+
+	policyTemplate, err := vc.readPolicyTemplate(VIEWER, VIEWER_POLICY_TEMPLATE)
+	if err != nil {
+		return viewerName, err
+	}
+
+	policy, err := renderPolicy(viewerName, policyTemplate, PolicyTemplateData{
+		ProfileName:    name,
+		Owner:          profile.Owner,
+		Namespace:      profile.Name,
+		MinioInstances: vc.MinioInstances,
+	})
+	if err != nil {
+		return viewerName, err
+	}
+
+	return viewerName, vc.writePolicy(viewerName, policy)
+}
+
+// writes the policy to Vault, unless it's already up to date
+func (vc *VaultConfigurerStruct) writePolicy(name, policy string) error {
 	var policyPath = fmt.Sprintf("/sys/policies/acl/%s", name)
 	secret, err := vc.Logical.Read(policyPath)
 	if err != nil {
-		return name, err
+		return err
 	}
 
 	if secret == nil || secret.Data["policy"].(string) != policy {
@@ -142,7 +478,7 @@ func (vc *VaultConfigurerStruct) doPolicy(name string) (string, error) {
 			"policy": policy,
 		})
 		if err != nil {
-			return name, err
+			return err
 		}
 
 		klog.Infof("policy %q created/updated", name)
@@ -150,7 +486,7 @@ func (vc *VaultConfigurerStruct) doPolicy(name string) (string, error) {
 		klog.Infof("policy %q already exists", name)
 	}
 
-	return name, nil
+	return nil
 }
 
 func hasMount(mounts map[string]*vault.MountOutput, mountName string) bool {
@@ -192,6 +528,22 @@ func (vc *VaultConfigurerStruct) doKVMount(name string) error {
 	return nil
 }
 
+// sets a scalar value for a key if it isn't equal
+func setScalarIfNotEquals(payload map[string]interface{}, key string, actual, expected interface{}) map[string]interface{} {
+	if actual == nil {
+		actual = reflect.Zero(reflect.TypeOf(expected)).Interface()
+	}
+
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		if payload == nil {
+			payload = map[string]interface{}{}
+		}
+		payload[key] = expected
+	}
+
+	return payload
+}
+
 // sets a value for a key if it isn't equal
 func setValueIfNotEquals(payload map[string]interface{}, key string, actual []interface{}, expected []string) map[string]interface{} {
 
@@ -211,7 +563,23 @@ func setValueIfNotEquals(payload map[string]interface{}, key string, actual []in
 	return payload
 }
 
-func (vc *VaultConfigurerStruct) doKubernetesBackendRole(namespace, roleName, policyName string) error {
+func (vc *VaultConfigurerStruct) doKubernetesBackendRole(namespace, roleName, policyName string, role KubernetesRoleConfig) error {
+	serviceAccounts := role.ServiceAccounts
+	if len(serviceAccounts) == 0 {
+		serviceAccounts = []string{"*"}
+	}
+
+	policies := []string{DEFAULT, policyName}
+	for _, policy := range role.Policies {
+		policies = appendUnique(policies, policy)
+	}
+
+	return vc.doKubernetesRole(roleName, []string{namespace}, serviceAccounts, policies, role)
+}
+
+// creates or updates a Kubernetes auth role granting the policies to the
+// ServiceAccounts of the namespaces, with the TTLs and audience of the role
+func (vc *VaultConfigurerStruct) doKubernetesRole(roleName string, namespaces, serviceAccounts, policies []string, role KubernetesRoleConfig) error {
 	rolePath := fmt.Sprintf("%s/role/%s", vc.KubernetesAuthPath, roleName)
 
 	secret, err := vc.Logical.Read(rolePath)
@@ -222,9 +590,8 @@ func (vc *VaultConfigurerStruct) doKubernetesBackendRole(namespace, roleName, po
 	var payload map[string]interface{}
 	var operation string
 
-	policies := []string{DEFAULT, policyName}
-	namespaces := []string{namespace}
-	serviceAccounts := []string{"*"}
+	tokenTTL := int64(role.TokenTTL.Seconds())
+	tokenMaxTTL := int64(role.TokenMaxTTL.Seconds())
 
 	if secret == nil {
 		klog.Infof("creating backend role in %q for %q", vc.KubernetesAuthPath, roleName)
@@ -233,6 +600,13 @@ func (vc *VaultConfigurerStruct) doKubernetesBackendRole(namespace, roleName, po
 			"bound_service_account_names":      serviceAccounts,
 			"bound_service_account_namespaces": namespaces,
 			"token_policies":                   policies,
+			"token_ttl":                        tokenTTL,
+			"token_max_ttl":                    tokenMaxTTL,
+		}
+
+		// The audience is only known by recent versions of Vault
+		if role.Audience != "" {
+			payload["audience"] = role.Audience
 		}
 
 		operation = "created"
@@ -248,6 +622,15 @@ func (vc *VaultConfigurerStruct) doKubernetesBackendRole(namespace, roleName, po
 
 		key = "token_policies"
 		payload = setValueIfNotEquals(payload, key, secret.Data[key].([]interface{}), policies)
+
+		key = "token_ttl"
+		payload = setScalarIfNotEquals(payload, key, secret.Data[key], tokenTTL)
+
+		key = "token_max_ttl"
+		payload = setScalarIfNotEquals(payload, key, secret.Data[key], tokenMaxTTL)
+
+		key = "audience"
+		payload = setScalarIfNotEquals(payload, key, secret.Data[key], role.Audience)
 	}
 
 	if payload != nil {
@@ -257,15 +640,104 @@ func (vc *VaultConfigurerStruct) doKubernetesBackendRole(namespace, roleName, po
 			return err
 		}
 
-		klog.Infof("backend role in %q for %q %s", vc.KubernetesAuthPath, namespace, operation)
+		klog.Infof("backend role in %q for %q %s", vc.KubernetesAuthPath, roleName, operation)
 	}
 
 	return nil
 }
 
+// serviceAccountRoleName returns the name of the Kubernetes auth role of the
+// ServiceAccounts of a namespace given an access level to a profile,
+// profile-<name>.<access>.<namespace>
+func serviceAccountRoleName(name, access, namespace string) string {
+	return fmt.Sprintf("%s.%s.%s", name, access, namespace)
+}
+
+// Creates a Kubernetes auth role per namespace of the ServiceAccounts,
+// given as namespace/name keys, so that a ServiceAccount of one namespace
+// can't log in with the name of a ServiceAccount of another one.
+// ServiceAccounts of the namespace of the profile are left to its backend role.
+func (vc *VaultConfigurerStruct) doServiceAccountRoles(profileName, name, access, policyName string, serviceAccounts []string, role KubernetesRoleConfig) ([]string, error) {
+	names := make(map[string][]string)
+	for _, key := range serviceAccounts {
+		parts := strings.SplitN(key, "/", 2)
+		if len(parts) != 2 || parts[0] == profileName {
+			continue
+		}
+
+		names[parts[0]] = append(names[parts[0]], parts[1])
+	}
+
+	roleNames := make([]string, 0, len(names))
+	errs := make([]error, 0)
+	for namespace, serviceAccountNames := range names {
+		roleName := serviceAccountRoleName(name, access, namespace)
+		roleNames = append(roleNames, roleName)
+
+		if err := vc.doKubernetesRole(roleName, []string{namespace}, serviceAccountNames, []string{DEFAULT, policyName}, role); err != nil {
+			errs = append(errs, fmt.Errorf("role %q: %v", roleName, err))
+		}
+	}
+
+	return roleNames, utilerrors.NewAggregate(errs)
+}
+
+// lists the Kubernetes auth roles of the ServiceAccounts given access to the profile
+func (vc *VaultConfigurerStruct) listServiceAccountRoles(name string) ([]string, error) {
+	secret, err := vc.Logical.List(fmt.Sprintf("%s/role", vc.KubernetesAuthPath))
+	if err != nil {
+		return nil, err
+	}
+
+	roleNames := make([]string, 0)
+	if secret == nil {
+		return roleNames, nil
+	}
+
+	keys, _ := secret.Data["keys"].([]interface{})
+	for _, key := range keys {
+		if roleName := fmt.Sprint(key); strings.HasPrefix(roleName, name+".") {
+			roleNames = append(roleNames, roleName)
+		}
+	}
+
+	return roleNames, nil
+}
+
+// removes the Kubernetes auth roles of the ServiceAccounts
+// which no longer have access to the profile
+func (vc *VaultConfigurerStruct) removeStaleServiceAccountRoles(name string, desired []string) error {
+	roleNames, err := vc.listServiceAccountRoles(name)
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, 0)
+	for _, roleName := range roleNames {
+		if StringArrayContains(desired, roleName) {
+			continue
+		}
+
+		if err := vc.doDelete(fmt.Sprintf("%s/role/%s", vc.KubernetesAuthPath, roleName)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// minioUserNamePrefix returns the prefix of the names of the MinIO users
+// created for a role of the Vault MinIO plugin. Profile names can't hold an
+// underscore, so the users of "profile-alice-bob" don't match the prefix of
+// "profile-alice", as they did with a dash.
+func minioUserNamePrefix(roleName string) string {
+	return roleName + "_"
+}
+
 //configures the minio secret stores for the given profile name
 func (vc *VaultConfigurerStruct) doMinioRole(authPath, name string) error {
 	rolePath := fmt.Sprintf("%s/roles/%s", authPath, name)
+	userNamePrefix := minioUserNamePrefix(name)
 
 	secret, err := vc.Logical.Read(rolePath)
 	if err != nil && !strings.Contains(err.Error(), "role not found") {
@@ -275,18 +747,42 @@ func (vc *VaultConfigurerStruct) doMinioRole(authPath, name string) error {
 	if secret == nil {
 		klog.Infof("creating backend role in %q for %q", authPath, name)
 
-		secret, err = vc.Logical.Write(rolePath, map[string]interface{}{
+		_, err = vc.Logical.Write(rolePath, map[string]interface{}{
 			"policy":           "readonly",
-			"user_name_prefix": fmt.Sprintf("%s-", name),
+			"user_name_prefix": userNamePrefix,
 		})
 
+		return err
+	}
+
+	// The roles created with the former prefix are brought to the new one.
+	// The shared buckets only let the users with the new prefix write, so
+	// the credentials issued with the former one are revoked: the users
+	// get new ones, with the new prefix, from Vault.
+	if secret.Data["user_name_prefix"] != userNamePrefix {
+		klog.Infof("updating the user name prefix of backend role in %q for %q", authPath, name)
+
+		policy, ok := secret.Data["policy"].(string)
+		if !ok || policy == "" {
+			policy = "readonly"
+		}
+
+		_, err = vc.Logical.Write(rolePath, map[string]interface{}{
+			"policy":           policy,
+			"user_name_prefix": userNamePrefix,
+		})
 		if err != nil {
 			return err
 		}
-	} else {
-		klog.Infof("backend role in %q for %q already exists", authPath, name)
+
+		klog.Infof("revoking the credentials of backend role in %q for %q", authPath, name)
+		_, err = vc.Logical.Write(fmt.Sprintf("sys/leases/revoke-prefix/%s/keys/%s", authPath, name), nil)
+
+		return err
 	}
 
+	klog.Infof("backend role in %q for %q already exists", authPath, name)
+
 	return nil
 }
 
@@ -306,7 +802,7 @@ func (vc *VaultConfigurerStruct) doEntity(name string) (string, error) {
 	if secret == nil {
 		secret, err = vc.Logical.Write(entityNamePath, map[string]interface{}{
 			"metadata": map[string]string{
-				"created_by": "kubeflow-controller",
+				"created_by": CREATED_BY,
 			},
 		})
 
@@ -381,7 +877,104 @@ func (vc *VaultConfigurerStruct) doEntityAlias(entityName string) error {
 	return nil
 }
 
-func (vc *VaultConfigurerStruct) doGroup(profileName, policyName string, entityIds []string) error {
+//
+// Creates an external group linked to the group of the same name in OIDC,
+// oidc.<groupName>, and returns its id
+//
+func (vc *VaultConfigurerStruct) doExternalGroup(groupName string) (string, error) {
+	name := fmt.Sprintf("oidc.%s", groupName)
+	groupPath := fmt.Sprintf("/identity/group/name/%s", name)
+
+	secret, err := vc.Logical.Read(groupPath)
+	if err != nil {
+		return "", err
+	}
+
+	if secret == nil {
+		_, err = vc.Logical.Write(groupPath, map[string]interface{}{
+			"type": "external",
+			"metadata": map[string]string{
+				"created_by": CREATED_BY,
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+
+		// Read the group back, its id isn't always returned
+		if secret, err = vc.Logical.Read(groupPath); err != nil {
+			return "", err
+		}
+
+		if secret == nil {
+			return "", fmt.Errorf("group %q not found after its creation", name)
+		}
+
+		klog.Infof("created external group %q", name)
+	}
+
+	groupId, _ := secret.Data["id"].(string)
+
+	alias, _ := secret.Data["alias"].(map[string]interface{})
+	if alias["name"] != groupName || alias["mount_accessor"] != vc.OidcAuthAccessor {
+		aliasPath := "/identity/group-alias"
+		if aliasId, ok := alias["id"].(string); ok && aliasId != "" {
+			aliasPath = fmt.Sprintf("/identity/group-alias/id/%s", aliasId)
+		}
+
+		_, err = vc.Logical.Write(aliasPath, map[string]interface{}{
+			"name":           groupName,
+			"mount_accessor": vc.OidcAuthAccessor,
+			"canonical_id":   groupId,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		klog.Infof("created/update group-alias for %q", groupName)
+	}
+
+	return groupId, nil
+}
+
+// Creates or updates the entities and their aliases, and returns their ids.
+// A failing entity doesn't prevent the others from being configured.
+func (vc *VaultConfigurerStruct) doEntities(entityNames []string) ([]string, []error) {
+	entityIds := make([]string, 0, len(entityNames))
+	errs := make([]error, 0)
+	for _, entityName := range entityNames {
+		id, err := vc.doEntity(entityName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("entity %q: %v", entityName, err))
+			continue
+		}
+		entityIds = append(entityIds, id)
+
+		if err := vc.doEntityAlias(entityName); err != nil {
+			errs = append(errs, fmt.Errorf("entity-alias %q: %v", entityName, err))
+		}
+	}
+
+	return entityIds, errs
+}
+
+// Creates or updates the external groups, and returns their ids
+func (vc *VaultConfigurerStruct) doExternalGroups(groupNames []string) ([]string, []error) {
+	groupIds := make([]string, 0, len(groupNames))
+	errs := make([]error, 0)
+	for _, groupName := range groupNames {
+		id, err := vc.doExternalGroup(groupName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("external group %q: %v", groupName, err))
+			continue
+		}
+		groupIds = append(groupIds, id)
+	}
+
+	return groupIds, errs
+}
+
+func (vc *VaultConfigurerStruct) doGroup(profileName, policyName string, entityIds, groupIds []string) error {
 	groupPath := fmt.Sprintf("/identity/group/name/%s", profileName)
 
 	secret, err := vc.Logical.Read(groupPath)
@@ -397,9 +990,10 @@ func (vc *VaultConfigurerStruct) doGroup(profileName, policyName string, entityI
 		payload = map[string]interface{}{
 			"policies":          policies,
 			"member_entity_ids": entityIds,
+			"member_group_ids":  groupIds,
 			"type":              "internal",
 			"metadata": map[string]string{
-				"created_by": "kubeflow-controller",
+				"created_by": CREATED_BY,
 			},
 		}
 
@@ -411,8 +1005,14 @@ func (vc *VaultConfigurerStruct) doGroup(profileName, policyName string, entityI
 		key := "policies"
 		payload = setValueIfNotEquals(payload, key, secret.Data[key].([]interface{}), policies)
 
+		// Vault returns null instead of an empty list of members
 		key = "member_entity_ids"
-		payload = setValueIfNotEquals(payload, key, secret.Data[key].([]interface{}), entityIds)
+		actual, _ := secret.Data[key].([]interface{})
+		payload = setValueIfNotEquals(payload, key, actual, entityIds)
+
+		key = "member_group_ids"
+		actual, _ = secret.Data[key].([]interface{})
+		payload = setValueIfNotEquals(payload, key, actual, groupIds)
 	}
 
 	if payload != nil {
@@ -426,8 +1026,20 @@ func (vc *VaultConfigurerStruct) doGroup(profileName, policyName string, entityI
 	return nil
 }
 
-func (vc *VaultConfigurerStruct) ConfigVaultForProfile(profileName, ownerName string, users []string) error {
+// ConfigVaultForProfile configures Vault for the profile, in the
+// Vault namespace of the profile.
+func (vc *VaultConfigurerStruct) ConfigVaultForProfile(profile SecretStoreProfile) error {
+	scoped, err := vc.forProfile(profile.Name)
+	if err != nil {
+		return err
+	}
+
+	return scoped.configVaultForProfile(profile)
+}
 
+func (vc *VaultConfigurerStruct) configVaultForProfile(profile SecretStoreProfile) error {
+
+	profileName := profile.Name
 	prefixedProfileName := fmt.Sprintf("profile-%s", profileName)
 
 	//
@@ -447,7 +1059,12 @@ func (vc *VaultConfigurerStruct) ConfigVaultForProfile(profileName, ownerName st
 	// ensure it has the right value.
 	//
 	var policyName string
-	if policyName, err = vc.doPolicy(prefixedProfileName); err != nil {
+	if policyName, err = vc.doPolicy(prefixedProfileName, profile); err != nil {
+		return err
+	}
+
+	var viewerPolicyName string
+	if viewerPolicyName, err = vc.doViewerPolicy(prefixedProfileName, profile); err != nil {
 		return err
 	}
 
@@ -458,7 +1075,26 @@ func (vc *VaultConfigurerStruct) ConfigVaultForProfile(profileName, ownerName st
 	// to permit authentication from the profile's
 	// namespace.
 	//
-	if err := vc.doKubernetesBackendRole(profileName, prefixedProfileName, policyName); err != nil {
+	role := vc.KubernetesRole.merge(profile.KubernetesRole)
+	if err := vc.doKubernetesBackendRole(profileName, prefixedProfileName, policyName, role); err != nil {
+		return err
+	}
+
+	//
+	// Add the Kubernetes backend roles of the
+	// ServiceAccounts of other namespaces.
+	//
+	editorRoles, err := vc.doServiceAccountRoles(profileName, prefixedProfileName, EDITOR, policyName, profile.Editors.ServiceAccounts, role)
+	if err != nil {
+		return err
+	}
+
+	viewerRoles, err := vc.doServiceAccountRoles(profileName, prefixedProfileName, VIEWER, viewerPolicyName, profile.Viewers.ServiceAccounts, role)
+	if err != nil {
+		return err
+	}
+
+	if err := vc.removeStaleServiceAccountRoles(prefixedProfileName, append(editorRoles, viewerRoles...)); err != nil {
 		return err
 	}
 
@@ -468,7 +1104,7 @@ func (vc *VaultConfigurerStruct) ConfigVaultForProfile(profileName, ownerName st
 	// Add MinIO role
 	//
 	for _, instance := range vc.MinioInstances {
-		if err := vc.doMinioRole(instance, prefixedProfileName); err != nil {
+		if err := vc.minio().doMinioRole(instance, prefixedProfileName); err != nil {
 			return err
 		}
 	}
@@ -476,29 +1112,151 @@ func (vc *VaultConfigurerStruct) ConfigVaultForProfile(profileName, ownerName st
 	klog.Info("done MinIO backend roles")
 
 	//
-	// Create the entity associated to the profile
+	// Create the entities and the external groups
+	// associated to the profile. The editors don't
+	// need to be viewers too.
 	//
-	entityNames := append(users, ownerName)
-	entityIds := make([]string, len(entityNames))
-	for i, entityName := range entityNames {
-		if id, err := vc.doEntity(entityName); err != nil {
-			return err
-		} else {
-			entityIds[i] = id
+	entityNames := append(append([]string{}, profile.Editors.Users...), profile.Owner)
+	entityIds, errs := vc.doEntities(entityNames)
+	groupIds, groupErrs := vc.doExternalGroups(profile.Editors.Groups)
+	errs = append(errs, groupErrs...)
+
+	viewerEntityNames := make([]string, 0, len(profile.Viewers.Users))
+	for _, name := range profile.Viewers.Users {
+		if !StringArrayContains(entityNames, name) {
+			viewerEntityNames = append(viewerEntityNames, name)
 		}
-		if err := vc.doEntityAlias(entityName); err != nil {
-			return err
+	}
+
+	viewerGroupNames := make([]string, 0, len(profile.Viewers.Groups))
+	for _, name := range profile.Viewers.Groups {
+		if !StringArrayContains(profile.Editors.Groups, name) {
+			viewerGroupNames = append(viewerGroupNames, name)
 		}
 	}
 
+	viewerEntityIds, viewerErrs := vc.doEntities(viewerEntityNames)
+	errs = append(errs, viewerErrs...)
+	viewerGroupIds, viewerGroupErrs := vc.doExternalGroups(viewerGroupNames)
+	errs = append(errs, viewerGroupErrs...)
+
+	// The group membership is only updated once every entity is known,
+	// otherwise a transient error would remove members from the group.
+	if len(errs) > 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+
 	klog.Infof("done creating entities and aliases")
 
-	err = vc.doGroup(prefixedProfileName, policyName, entityIds)
+	err = vc.doGroup(prefixedProfileName, policyName, entityIds, groupIds)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("group %q: %v", prefixedProfileName, err))
+	}
+
+	viewerGroupName := fmt.Sprintf("%s.%s", prefixedProfileName, VIEWER)
+	err = vc.doGroup(viewerGroupName, viewerPolicyName, viewerEntityIds, viewerGroupIds)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("group %q: %v", viewerGroupName, err))
+	}
+
+	if len(errs) == 0 {
+		klog.Infof("done creating groups")
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// removes the KV secret store of the profile, or moves it under
+// the archive prefix if the data should be kept
+func (vc *VaultConfigurerStruct) undoKVMount(name string, purge bool) error {
+	mountName := fmt.Sprintf("kv_%s", name)
+
+	mounts, err := vc.Mounts.ListMounts()
 	if err != nil {
 		return err
 	}
 
-	klog.Infof("done creating group")
+	if !hasMount(mounts, mountName) {
+		klog.Infof("mount %q already removed", mountName)
+		return nil
+	}
+
+	if purge {
+		klog.Infof("removing mount %q", mountName)
+		return vc.Mounts.Unmount(mountName)
+	}
+
+	archiveName := fmt.Sprintf("%s/%s-%d", ARCHIVE_PREFIX, mountName, time.Now().Unix())
+	klog.Infof("archiving mount %q to %q", mountName, archiveName)
+	return vc.Mounts.Remount(mountName, archiveName)
+}
+
+// deletes a path from Vault, ignoring paths that are already gone
+func (vc *VaultConfigurerStruct) doDelete(path string) error {
+	_, err := vc.Logical.Delete(path)
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+
+	klog.Infof("deleted %q", path)
+	return nil
+}
+
+// DeconfigVaultForProfile removes the Vault configuration created
+// by ConfigVaultForProfile. Entities and external groups are shared
+// between profiles and are left in place.
+func (vc *VaultConfigurerStruct) DeconfigVaultForProfile(profileName string, purge bool) error {
+	scoped, err := vc.forProfile(profileName)
+	if err != nil {
+		return err
+	}
+
+	return scoped.deconfigVaultForProfile(profileName, purge)
+}
+
+func (vc *VaultConfigurerStruct) deconfigVaultForProfile(profileName string, purge bool) error {
+
+	prefixedProfileName := fmt.Sprintf("profile-%s", profileName)
+
+	//
+	// Remove the group first so members
+	// lose access before anything else is removed.
+	//
+	if err := vc.doDelete(fmt.Sprintf("/identity/group/name/%s", prefixedProfileName)); err != nil {
+		return err
+	}
+
+	if err := vc.doDelete(fmt.Sprintf("/identity/group/name/%s.%s", prefixedProfileName, VIEWER)); err != nil {
+		return err
+	}
+
+	for _, instance := range vc.MinioInstances {
+		if err := vc.minio().doDelete(fmt.Sprintf("%s/roles/%s", instance, prefixedProfileName)); err != nil {
+			return err
+		}
+	}
+
+	if err := vc.doDelete(fmt.Sprintf("%s/role/%s", vc.KubernetesAuthPath, prefixedProfileName)); err != nil {
+		return err
+	}
+
+	if err := vc.removeStaleServiceAccountRoles(prefixedProfileName, nil); err != nil {
+		return err
+	}
+
+	if err := vc.doDelete(fmt.Sprintf("/sys/policies/acl/%s", prefixedProfileName)); err != nil {
+		return err
+	}
+
+	if err := vc.doDelete(fmt.Sprintf("/sys/policies/acl/%s.%s", prefixedProfileName, VIEWER)); err != nil {
+		return err
+	}
+
+	if err := vc.undoKVMount(prefixedProfileName, purge); err != nil {
+		return err
+	}
+
+	klog.Infof("done removing Vault configuration for %q", profileName)
 
 	return nil
 }
@@ -508,6 +1266,13 @@ type MinIOConfiguration struct {
 	Endpoint        string `json:"endpoint"`
 	SecretAccessKey string `json:"secretAccessKey"`
 	UseSSL          bool   `json:"useSSL"`
+
+	// LeaseDuration of the secret the configuration was read from,
+	// zero when it has no lease.
+	LeaseDuration time.Duration `json:"-"`
+	// Region and PathStyle are set by the configuration of the instance.
+	Region    string `json:"-"`
+	PathStyle bool   `json:"-"`
 }
 
 // GetMinIOConfiguration returns the MinIO configuration.
@@ -517,7 +1282,13 @@ func (vc *VaultConfigurerStruct) GetMinIOConfiguration(instance string) (*MinIOC
 		return nil, err
 	}
 
-	config := MinIOConfiguration{}
+	if data == nil {
+		return nil, fmt.Errorf("no MinIO configuration found for instance %q", instance)
+	}
+
+	config := MinIOConfiguration{
+		LeaseDuration: time.Duration(data.LeaseDuration) * time.Second,
+	}
 
 	if val, ok := data.Data["accessKeyId"]; ok {
 		config.AccessKeyID = val.(string)
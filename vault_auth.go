@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"k8s.io/klog"
+)
+
+// Methods the controller can authenticate to Vault with.
+const (
+	// VaultAuthAgent relies on a Vault agent sidecar to authenticate the requests.
+	VaultAuthAgent = "agent"
+	// VaultAuthKubernetes logs in with the token of the ServiceAccount of the controller.
+	VaultAuthKubernetes = "kubernetes"
+	// VaultAuthAppRole logs in with an AppRole role ID and secret ID.
+	VaultAuthAppRole = "approle"
+	// VaultAuthTokenFile uses the token written to a file.
+	VaultAuthTokenFile = "token-file"
+)
+
+// VaultAuthConfig configures how the controller authenticates to Vault
+type VaultAuthConfig struct {
+	Method string
+	// MountPath of the auth method, defaults to the name of the method.
+	MountPath string
+
+	// Role and ServiceAccountTokenPath are used by the Kubernetes auth method.
+	Role                    string
+	ServiceAccountTokenPath string
+
+	// RoleID and SecretIDPath are used by the AppRole auth method.
+	RoleID       string
+	SecretIDPath string
+
+	// TokenPath is used by the token-file method.
+	TokenPath string
+
+	// RetryPeriod is the duration between failed login attempts.
+	RetryPeriod time.Duration
+}
+
+// VaultAuthenticator logs the Vault client in and keeps its token valid
+type VaultAuthenticator struct {
+	client *vault.Client
+	config VaultAuthConfig
+}
+
+// NewVaultAuthenticator returns an authenticator for the Vault client.
+func NewVaultAuthenticator(client *vault.Client, config VaultAuthConfig) *VaultAuthenticator {
+	return &VaultAuthenticator{
+		client: client,
+		config: config,
+	}
+}
+
+// Login authenticates with the configured method, sets the token
+// of the client and returns the secret holding the token.
+func (a *VaultAuthenticator) Login() (*vault.Secret, error) {
+	var secret *vault.Secret
+	var err error
+
+	switch a.config.Method {
+	case VaultAuthKubernetes:
+		secret, err = a.loginKubernetes()
+	case VaultAuthAppRole:
+		secret, err = a.loginAppRole()
+	case VaultAuthTokenFile:
+		secret, err = a.loginTokenFile()
+	default:
+		return nil, fmt.Errorf("unsupported Vault auth method %q", a.config.Method)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("no token returned by the %s auth method", a.config.Method)
+	}
+
+	a.client.SetToken(secret.Auth.ClientToken)
+	return secret, nil
+}
+
+func (a *VaultAuthenticator) loginPath() string {
+	mountPath := a.config.MountPath
+	if mountPath == "" {
+		mountPath = a.config.Method
+	}
+
+	return path.Join("auth", mountPath, "login")
+}
+
+func (a *VaultAuthenticator) loginKubernetes() (*vault.Secret, error) {
+	jwt, err := readFileString(a.config.ServiceAccountTokenPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.client.Logical().Write(a.loginPath(), map[string]interface{}{
+		"role": a.config.Role,
+		"jwt":  jwt,
+	})
+}
+
+func (a *VaultAuthenticator) loginAppRole() (*vault.Secret, error) {
+	secretID, err := readFileString(a.config.SecretIDPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.client.Logical().Write(a.loginPath(), map[string]interface{}{
+		"role_id":   a.config.RoleID,
+		"secret_id": secretID,
+	})
+}
+
+// loginTokenFile looks up the token of the file, so it can be
+// renewed like the tokens returned by the other methods.
+func (a *VaultAuthenticator) loginTokenFile() (*vault.Secret, error) {
+	token, err := readFileString(a.config.TokenPath)
+	if err != nil {
+		return nil, err
+	}
+
+	a.client.SetToken(token)
+	lookup, err := a.client.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, err
+	}
+
+	renewable, _ := lookup.Data["renewable"].(bool)
+
+	var ttl int64
+	if value, ok := lookup.Data["ttl"].(json.Number); ok {
+		ttl, _ = value.Int64()
+	}
+
+	return &vault.Secret{
+		Auth: &vault.SecretAuth{
+			ClientToken:   token,
+			Renewable:     renewable,
+			LeaseDuration: int(ttl),
+		},
+	}, nil
+}
+
+// Run keeps the token of the client valid until stopCh is closed. The token
+// is renewed in the background and the client logs in again when the token
+// can't be renewed anymore.
+func (a *VaultAuthenticator) Run(secret *vault.Secret, stopCh <-chan struct{}) {
+	for {
+		if secret == nil {
+			var err error
+			if secret, err = a.Login(); err != nil {
+				klog.Errorf("failed to log in to Vault with the %s auth method: %v", a.config.Method, err)
+
+				select {
+				case <-stopCh:
+					return
+				case <-time.After(a.config.RetryPeriod):
+				}
+				continue
+			}
+
+			klog.Infof("logged in to Vault with the %s auth method", a.config.Method)
+		}
+
+		if !a.keepToken(secret, stopCh) {
+			return
+		}
+
+		secret = nil
+	}
+}
+
+// keepToken renews the token until it expires or can't be renewed.
+// It returns false once stopCh is closed.
+func (a *VaultAuthenticator) keepToken(secret *vault.Secret, stopCh <-chan struct{}) bool {
+	if !secret.Auth.Renewable {
+		// A token without a TTL never expires
+		if secret.Auth.LeaseDuration <= 0 {
+			<-stopCh
+			return false
+		}
+
+		// Log in again before the token expires
+		select {
+		case <-stopCh:
+			return false
+		case <-time.After(time.Duration(secret.Auth.LeaseDuration) * time.Second * 2 / 3):
+			return true
+		}
+	}
+
+	renewer, err := a.client.NewRenewer(&vault.RenewerInput{
+		Secret: secret,
+	})
+	if err != nil {
+		klog.Errorf("failed to renew the Vault token: %v", err)
+		return true
+	}
+
+	go renewer.Renew()
+	defer renewer.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return false
+		case err := <-renewer.DoneCh():
+			if err != nil {
+				klog.Errorf("failed to renew the Vault token: %v", err)
+			}
+			return true
+		case renewal := <-renewer.RenewCh():
+			klog.V(4).Infof("renewed the Vault token at %s", renewal.RenewedAt)
+		}
+	}
+}
+
+func readFileString(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
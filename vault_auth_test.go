@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// fakeVault serves the login, lookup and renewal requests of the auth methods.
+type fakeVault struct {
+	mutex sync.Mutex
+	// logins holds the body of each login request
+	logins   []map[string]interface{}
+	renewals []string
+
+	// lookup and renew return the token data for the token of the request
+	lookup func(token string) map[string]interface{}
+	renew  func(token string) map[string]interface{}
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	token := r.Header.Get("X-Vault-Token")
+	var response map[string]interface{}
+
+	switch r.URL.Path {
+	case "/v1/auth/kubernetes/login", "/v1/auth/custom/login", "/v1/auth/approle/login":
+		body := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&body)
+		body["path"] = r.URL.Path
+		v.logins = append(v.logins, body)
+
+		response = map[string]interface{}{"auth": map[string]interface{}{
+			"client_token":   fmt.Sprintf("token-%d", len(v.logins)),
+			"renewable":      true,
+			"lease_duration": 3600,
+		}}
+	case "/v1/auth/token/lookup-self":
+		response = map[string]interface{}{"data": v.lookup(token)}
+	case "/v1/auth/token/renew-self":
+		v.renewals = append(v.renewals, token)
+		response = map[string]interface{}{"auth": v.renew(token)}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"errors":[]}`)
+		return
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (v *fakeVault) loginCount() int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return len(v.logins)
+}
+
+func newTestVaultClient(t *testing.T, handler http.Handler) (*vault.Client, func()) {
+	server := httptest.NewServer(handler)
+
+	config := vault.DefaultConfig()
+	config.Address = server.URL
+	client, err := vault.NewClient(config)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	client.ClearToken()
+
+	return client, server.Close
+}
+
+// waitFor polls the condition until it holds or the timeout expires.
+func waitFor(condition func() bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return condition()
+}
+
+func TestVaultAuthenticatorLogin(t *testing.T) {
+	credentials := writeTestFile(t, "credential\n")
+	defer os.Remove(credentials)
+
+	tests := map[string]struct {
+		config       VaultAuthConfig
+		expectLogin  map[string]interface{}
+		expectToken  string
+		expectLease  int
+		expectRenews bool
+	}{
+		VaultAuthKubernetes: {
+			config: VaultAuthConfig{Method: VaultAuthKubernetes, Role: "controller", ServiceAccountTokenPath: credentials},
+			expectLogin: map[string]interface{}{
+				"path": "/v1/auth/kubernetes/login",
+				"role": "controller",
+				"jwt":  "credential",
+			},
+			expectToken:  "token-1",
+			expectLease:  3600,
+			expectRenews: true,
+		},
+		"kubernetes mount path": {
+			config: VaultAuthConfig{Method: VaultAuthKubernetes, MountPath: "custom", Role: "controller", ServiceAccountTokenPath: credentials},
+			expectLogin: map[string]interface{}{
+				"path": "/v1/auth/custom/login",
+				"role": "controller",
+				"jwt":  "credential",
+			},
+			expectToken:  "token-1",
+			expectLease:  3600,
+			expectRenews: true,
+		},
+		VaultAuthAppRole: {
+			config: VaultAuthConfig{Method: VaultAuthAppRole, RoleID: "role-id", SecretIDPath: credentials},
+			expectLogin: map[string]interface{}{
+				"path":      "/v1/auth/approle/login",
+				"role_id":   "role-id",
+				"secret_id": "credential",
+			},
+			expectToken:  "token-1",
+			expectLease:  3600,
+			expectRenews: true,
+		},
+		// The token of the file is looked up instead of logging in
+		VaultAuthTokenFile: {
+			config:      VaultAuthConfig{Method: VaultAuthTokenFile, TokenPath: credentials},
+			expectToken: "credential",
+			expectLease: 60,
+		},
+	}
+
+	for name, test := range tests {
+		fake := &fakeVault{
+			lookup: func(token string) map[string]interface{} {
+				return map[string]interface{}{"renewable": false, "ttl": 60}
+			},
+		}
+		client, stop := newTestVaultClient(t, fake)
+
+		secret, err := NewVaultAuthenticator(client, test.config).Login()
+		stop()
+		if err != nil {
+			t.Errorf("%s: Unexpected error %v", name, err)
+			continue
+		}
+
+		if test.expectLogin == nil && len(fake.logins) != 0 {
+			t.Errorf("%s: Expected no login, got %v", name, fake.logins)
+		} else if test.expectLogin != nil && (len(fake.logins) != 1 || !mapsEqual(fake.logins[0], test.expectLogin)) {
+			t.Errorf("%s: Expected the login %v, got %v", name, test.expectLogin, fake.logins)
+		}
+
+		if client.Token() != test.expectToken {
+			t.Errorf("%s: Expected the client to use the token %q, got %q", name, test.expectToken, client.Token())
+		}
+
+		if secret.Auth.LeaseDuration != test.expectLease || secret.Auth.Renewable != test.expectRenews {
+			t.Errorf("%s: Expected a lease of %d renewable %t, got %+v", name, test.expectLease, test.expectRenews, secret.Auth)
+		}
+	}
+}
+
+func TestVaultAuthenticatorLogin_invalid(t *testing.T) {
+	tests := map[string]VaultAuthConfig{
+		"unsupported method": {Method: VaultAuthAgent},
+		"missing token":      {Method: VaultAuthKubernetes, ServiceAccountTokenPath: "/nonexistent/token"},
+	}
+
+	for name, config := range tests {
+		client, stop := newTestVaultClient(t, &fakeVault{})
+		if _, err := NewVaultAuthenticator(client, config).Login(); err == nil {
+			t.Errorf("%s: Expected an error", name)
+		}
+		stop()
+	}
+}
+
+// Tests that the token is renewed and that the client
+// logs in again once the token can't be renewed anymore
+func TestVaultAuthenticatorRun_relogin(t *testing.T) {
+	credentials := writeTestFile(t, "jwt")
+	defer os.Remove(credentials)
+
+	fake := &fakeVault{
+		renew: func(token string) map[string]interface{} {
+			// The first token reaches its max TTL on its renewal
+			lease := 3600
+			if token == "token-1" {
+				lease = 1
+			}
+			return map[string]interface{}{"client_token": token, "renewable": true, "lease_duration": lease}
+		},
+	}
+	client, stop := newTestVaultClient(t, fake)
+	defer stop()
+
+	authenticator := NewVaultAuthenticator(client, VaultAuthConfig{
+		Method:                  VaultAuthKubernetes,
+		Role:                    "controller",
+		ServiceAccountTokenPath: credentials,
+		RetryPeriod:             time.Millisecond,
+	})
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		authenticator.Run(nil, stopCh)
+		close(done)
+	}()
+
+	if !waitFor(func() bool { return fake.loginCount() == 2 && client.Token() == "token-2" }, 5*time.Second) {
+		t.Errorf("Expected the client to log in again, got %d logins", fake.loginCount())
+	}
+
+	close(stopCh)
+	<-done
+
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	if len(fake.renewals) == 0 || fake.renewals[0] != "token-1" {
+		t.Errorf("Expected the first token to be renewed, got %v", fake.renewals)
+	}
+	if len(fake.logins) != 2 {
+		t.Errorf("Expected the second token to be kept, got %d logins", len(fake.logins))
+	}
+}
+
+// Tests that the file is read again before a token
+// that can't be renewed expires
+func TestVaultAuthenticatorRun_tokenFile(t *testing.T) {
+	tokenPath := writeTestFile(t, "first")
+	defer os.Remove(tokenPath)
+
+	fake := &fakeVault{
+		lookup: func(token string) map[string]interface{} {
+			// The second token never expires
+			ttl := 0
+			if token == "first" {
+				ttl = 1
+			}
+			return map[string]interface{}{"renewable": false, "ttl": ttl}
+		},
+	}
+	client, stop := newTestVaultClient(t, fake)
+	defer stop()
+
+	authenticator := NewVaultAuthenticator(client, VaultAuthConfig{
+		Method:      VaultAuthTokenFile,
+		TokenPath:   tokenPath,
+		RetryPeriod: time.Millisecond,
+	})
+
+	secret, err := authenticator.Login()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(tokenPath, []byte("second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		authenticator.Run(secret, stopCh)
+		close(done)
+	}()
+
+	if !waitFor(func() bool { return client.Token() == "second" }, 5*time.Second) {
+		t.Errorf("Expected the token file to be read again, got %q", client.Token())
+	}
+
+	close(stopCh)
+	<-done
+}
+
+func mapsEqual(a, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for key, value := range a {
+		if b[key] != value {
+			return false
+		}
+	}
+	return true
+}
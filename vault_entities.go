@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/klog"
+)
+
+const (
+	// CREATED_BY is the metadata value set on the entities
+	// and groups created by the controller
+	CREATED_BY = "kubeflow-controller"
+	// ORPHANED_SINCE is the metadata key set on the entities which
+	// no longer belong to a profile, to the time they were found orphaned
+	ORPHANED_SINCE = "orphaned_since"
+)
+
+// auditEntity logs what the sweep did to an entity. The scope is the
+// profile whose Vault namespace holds the entity, if any.
+func auditEntity(action, scope, name, reason string) {
+	klog.Infof("audit: vault-entity-sweep action=%s scope=%q entity=%q reason=%q", action, scope, name, reason)
+}
+
+// SweepEntities removes the entities created by the controller which have
+// not belonged to any profile for longer than the grace period. The
+// entities are given as the owner and contributors of every profile.
+//
+// Orphaned entities are first marked with the time they were found
+// orphaned, so the grace period survives restarts of the controller.
+func (vc *VaultConfigurerStruct) SweepEntities(profiles map[string][]string, gracePeriod time.Duration) error {
+	now := time.Now()
+
+	// Without a namespace per profile, the entities
+	// of every profile share the same namespace
+	if vc.Client == nil || vc.ProfileNamespace == "" {
+		entityNames := make([]string, 0)
+		for _, names := range profiles {
+			entityNames = append(entityNames, names...)
+		}
+
+		return vc.sweepEntities("", entityNames, gracePeriod, now)
+	}
+
+	errs := make([]error, 0)
+	for profileName, entityNames := range profiles {
+		scoped, err := vc.forProfile(profileName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", profileName, err))
+			continue
+		}
+
+		if err := scoped.sweepEntities(profileName, entityNames, gracePeriod, now); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", profileName, err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func (vc *VaultConfigurerStruct) sweepEntities(scope string, desired []string, gracePeriod time.Duration, now time.Time) error {
+	secret, err := vc.Logical.List("/identity/entity/name")
+	if err != nil {
+		return err
+	}
+
+	if secret == nil {
+		return nil
+	}
+
+	keys, _ := secret.Data["keys"].([]interface{})
+	errs := make([]error, 0)
+	for _, key := range keys {
+		name := fmt.Sprint(key)
+		if err := vc.sweepEntity(scope, name, StringArrayContains(desired, name), gracePeriod, now); err != nil {
+			errs = append(errs, fmt.Errorf("entity %q: %v", name, err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// marks, unmarks or removes an entity created by the controller
+func (vc *VaultConfigurerStruct) sweepEntity(scope, name string, desired bool, gracePeriod time.Duration, now time.Time) error {
+	entityNamePath := fmt.Sprintf("/identity/entity/name/%s", name)
+
+	secret, err := vc.Logical.Read(entityNamePath)
+	if err != nil || secret == nil {
+		return err
+	}
+
+	metadata, _ := secret.Data["metadata"].(map[string]interface{})
+	if metadata["created_by"] != CREATED_BY {
+		return nil
+	}
+
+	orphanedSince, orphaned := metadata[ORPHANED_SINCE].(string)
+
+	if desired {
+		if !orphaned {
+			return nil
+		}
+
+		updated := copyEntityMetadata(metadata)
+		delete(updated, ORPHANED_SINCE)
+
+		_, err = vc.Logical.Write(entityNamePath, map[string]interface{}{
+			"metadata": updated,
+		})
+		if err == nil {
+			auditEntity("unmark", scope, name, "belongs to a profile again")
+		}
+		return err
+	}
+
+	if !orphaned {
+		updated := copyEntityMetadata(metadata)
+		updated[ORPHANED_SINCE] = now.UTC().Format(time.RFC3339)
+
+		_, err = vc.Logical.Write(entityNamePath, map[string]interface{}{
+			"metadata": updated,
+		})
+		if err == nil {
+			auditEntity("mark", scope, name, "no longer belongs to a profile")
+		}
+		return err
+	}
+
+	since, err := time.Parse(time.RFC3339, orphanedSince)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %v", ORPHANED_SINCE, orphanedSince, err)
+	}
+
+	if now.Sub(since) < gracePeriod {
+		klog.V(4).Infof("entity %q orphaned since %s, within the grace period", name, orphanedSince)
+		return nil
+	}
+
+	// Deleting the entity deletes its aliases too
+	if _, err = vc.Logical.Delete(entityNamePath); err != nil {
+		return err
+	}
+
+	auditEntity("delete", scope, name, fmt.Sprintf("orphaned since %s", orphanedSince))
+	return nil
+}
+
+// copyEntityMetadata copies the metadata of an entity, so the keys
+// set by others are kept when it's written back: Vault replaces the
+// whole metadata of the entity.
+func copyEntityMetadata(metadata map[string]interface{}) map[string]string {
+	copied := make(map[string]string, len(metadata)+1)
+	for key, value := range metadata {
+		copied[key] = fmt.Sprint(value)
+	}
+
+	return copied
+}
+
+// VaultEntitySweepConfig configures the periodic removal of the Vault
+// entities which no longer belong to a profile
+type VaultEntitySweepConfig struct {
+	// Period between two sweeps, the sweep is disabled when it's zero.
+	Period time.Duration
+	// GracePeriod an entity is kept for once it no longer belongs to a profile.
+	GracePeriod time.Duration
+}
+
+// sweepVaultEntities removes the Vault entities of the users who are
+// no longer the owner or a contributor of any Profile.
+func (c *Controller) sweepVaultEntities() {
+	profiles, err := c.profilesLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("vault entity sweep: %v", err))
+		return
+	}
+
+	entityNames := make(map[string][]string, len(profiles))
+	for _, profile := range profiles {
+		editors, viewers, err := c.vaultSubjects(profile)
+		// Don't sweep anything if the desired state is incomplete
+		if err != nil {
+			utilruntime.HandleError(fmt.Errorf("vault entity sweep: %s: %v", profile.Name, err))
+			return
+		}
+
+		names := []string{profile.Spec.Owner.Name}
+		for _, name := range append(editors.Users, viewers.Users...) {
+			names = appendUnique(names, name)
+		}
+		entityNames[profile.Name] = names
+	}
+
+	klog.Infof("sweeping the Vault entities of %d profiles", len(entityNames))
+	if err := c.secretStore.SweepSubjects(entityNames, c.vaultEntitySweep.GracePeriod); err != nil {
+		utilruntime.HandleError(fmt.Errorf("vault entity sweep: %v", err))
+	}
+}
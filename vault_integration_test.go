@@ -86,7 +86,7 @@ func TestDoEntity(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	vaultConfigurer := NewVaultConfigurer(vaultClient, "", "", minioTestInstances)
+	vaultConfigurer := NewVaultConfigurer(vaultClient, "", "", minioTestInstances, "")
 
 	id, err := vaultConfigurer.doEntity("jane.doe@test.ca")
 
@@ -111,9 +111,15 @@ func TestConfigureVaultForProfile(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	vaultConfigurer := NewVaultConfigurer(vaultClient, kubernetesTestPath, oidcAccessor, minioTestInstances)
+	vaultConfigurer := NewVaultConfigurer(vaultClient, kubernetesTestPath, oidcAccessor, minioTestInstances, "")
 
-	err = vaultConfigurer.ConfigVaultForProfile("random-test45", "jeremy.smith@test.ca", []string{"mandy.doe@test.ca"})
+	err = vaultConfigurer.ConfigVaultForProfile(SecretStoreProfile{
+		Name:  "random-test45",
+		Owner: "jeremy.smith@test.ca",
+		Editors: SecretStoreSubjects{
+			Users: []string{"mandy.doe@test.ca"},
+		},
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
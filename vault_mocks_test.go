@@ -9,8 +9,10 @@ import (
 )
 
 var (
-	lockVaultLogicalAPIMockRead  sync.RWMutex
-	lockVaultLogicalAPIMockWrite sync.RWMutex
+	lockVaultLogicalAPIMockDelete sync.RWMutex
+	lockVaultLogicalAPIMockList   sync.RWMutex
+	lockVaultLogicalAPIMockRead   sync.RWMutex
+	lockVaultLogicalAPIMockWrite  sync.RWMutex
 )
 
 // Ensure, that VaultLogicalAPIMock does implement VaultLogicalAPI.
@@ -23,6 +25,12 @@ var _ VaultLogicalAPI = &VaultLogicalAPIMock{}
 //
 //	// make and configure a mocked VaultLogicalAPI
 //	mockedVaultLogicalAPI := &VaultLogicalAPIMock{
+//		DeleteFunc: func(path string) (*api.Secret, error) {
+//			panic("mock out the Delete method")
+//		},
+//		ListFunc: func(path string) (*api.Secret, error) {
+//			panic("mock out the List method")
+//		},
 //		ReadFunc: func(path string) (*api.Secret, error) {
 //			panic("mock out the Read method")
 //		},
@@ -36,6 +44,12 @@ var _ VaultLogicalAPI = &VaultLogicalAPIMock{}
 //
 //}
 type VaultLogicalAPIMock struct {
+	// DeleteFunc mocks the Delete method.
+	DeleteFunc func(path string) (*api.Secret, error)
+
+	// ListFunc mocks the List method.
+	ListFunc func(path string) (*api.Secret, error)
+
 	// ReadFunc mocks the Read method.
 	ReadFunc func(path string) (*api.Secret, error)
 
@@ -44,6 +58,16 @@ type VaultLogicalAPIMock struct {
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// Delete holds details about calls to the Delete method.
+		Delete []struct {
+			// Path is the path argument value.
+			Path string
+		}
+		// List holds details about calls to the List method.
+		List []struct {
+			// Path is the path argument value.
+			Path string
+		}
 		// Read holds details about calls to the Read method.
 		Read []struct {
 			// Path is the path argument value.
@@ -59,6 +83,68 @@ type VaultLogicalAPIMock struct {
 	}
 }
 
+// Delete calls DeleteFunc.
+func (mock *VaultLogicalAPIMock) Delete(path string) (*api.Secret, error) {
+	if mock.DeleteFunc == nil {
+		panic("VaultLogicalAPIMock.DeleteFunc: method is nil but VaultLogicalAPI.Delete was just called")
+	}
+	callInfo := struct {
+		Path string
+	}{
+		Path: path,
+	}
+	lockVaultLogicalAPIMockDelete.Lock()
+	mock.calls.Delete = append(mock.calls.Delete, callInfo)
+	lockVaultLogicalAPIMockDelete.Unlock()
+	return mock.DeleteFunc(path)
+}
+
+// DeleteCalls gets all the calls that were made to Delete.
+// Check the length with:
+//     len(mockedVaultLogicalAPI.DeleteCalls())
+func (mock *VaultLogicalAPIMock) DeleteCalls() []struct {
+	Path string
+} {
+	var calls []struct {
+		Path string
+	}
+	lockVaultLogicalAPIMockDelete.RLock()
+	calls = mock.calls.Delete
+	lockVaultLogicalAPIMockDelete.RUnlock()
+	return calls
+}
+
+// List calls ListFunc.
+func (mock *VaultLogicalAPIMock) List(path string) (*api.Secret, error) {
+	if mock.ListFunc == nil {
+		panic("VaultLogicalAPIMock.ListFunc: method is nil but VaultLogicalAPI.List was just called")
+	}
+	callInfo := struct {
+		Path string
+	}{
+		Path: path,
+	}
+	lockVaultLogicalAPIMockList.Lock()
+	mock.calls.List = append(mock.calls.List, callInfo)
+	lockVaultLogicalAPIMockList.Unlock()
+	return mock.ListFunc(path)
+}
+
+// ListCalls gets all the calls that were made to List.
+// Check the length with:
+//     len(mockedVaultLogicalAPI.ListCalls())
+func (mock *VaultLogicalAPIMock) ListCalls() []struct {
+	Path string
+} {
+	var calls []struct {
+		Path string
+	}
+	lockVaultLogicalAPIMockList.RLock()
+	calls = mock.calls.List
+	lockVaultLogicalAPIMockList.RUnlock()
+	return calls
+}
+
 // Read calls ReadFunc.
 func (mock *VaultLogicalAPIMock) Read(path string) (*api.Secret, error) {
 	if mock.ReadFunc == nil {
@@ -128,6 +214,8 @@ func (mock *VaultLogicalAPIMock) WriteCalls() []struct {
 var (
 	lockVaultMountsAPIMockListMounts sync.RWMutex
 	lockVaultMountsAPIMockMount      sync.RWMutex
+	lockVaultMountsAPIMockRemount    sync.RWMutex
+	lockVaultMountsAPIMockUnmount    sync.RWMutex
 )
 
 // Ensure, that VaultMountsAPIMock does implement VaultMountsAPI.
@@ -146,6 +234,12 @@ var _ VaultMountsAPI = &VaultMountsAPIMock{}
 //             MountFunc: func(path string, mountInfo *api.MountInput) error {
 // 	               panic("mock out the Mount method")
 //             },
+//             RemountFunc: func(from string, to string) error {
+// 	               panic("mock out the Remount method")
+//             },
+//             UnmountFunc: func(path string) error {
+// 	               panic("mock out the Unmount method")
+//             },
 //         }
 //
 //         // use mockedVaultMountsAPI in code that requires VaultMountsAPI
@@ -159,6 +253,12 @@ type VaultMountsAPIMock struct {
 	// MountFunc mocks the Mount method.
 	MountFunc func(path string, mountInfo *api.MountInput) error
 
+	// RemountFunc mocks the Remount method.
+	RemountFunc func(from string, to string) error
+
+	// UnmountFunc mocks the Unmount method.
+	UnmountFunc func(path string) error
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// ListMounts holds details about calls to the ListMounts method.
@@ -171,6 +271,18 @@ type VaultMountsAPIMock struct {
 			// MountInfo is the mountInfo argument value.
 			MountInfo *api.MountInput
 		}
+		// Remount holds details about calls to the Remount method.
+		Remount []struct {
+			// From is the from argument value.
+			From string
+			// To is the to argument value.
+			To string
+		}
+		// Unmount holds details about calls to the Unmount method.
+		Unmount []struct {
+			// Path is the path argument value.
+			Path string
+		}
 	}
 }
 
@@ -234,3 +346,69 @@ func (mock *VaultMountsAPIMock) MountCalls() []struct {
 	lockVaultMountsAPIMockMount.RUnlock()
 	return calls
 }
+
+// Remount calls RemountFunc.
+func (mock *VaultMountsAPIMock) Remount(from string, to string) error {
+	if mock.RemountFunc == nil {
+		panic("VaultMountsAPIMock.RemountFunc: method is nil but VaultMountsAPI.Remount was just called")
+	}
+	callInfo := struct {
+		From string
+		To   string
+	}{
+		From: from,
+		To:   to,
+	}
+	lockVaultMountsAPIMockRemount.Lock()
+	mock.calls.Remount = append(mock.calls.Remount, callInfo)
+	lockVaultMountsAPIMockRemount.Unlock()
+	return mock.RemountFunc(from, to)
+}
+
+// RemountCalls gets all the calls that were made to Remount.
+// Check the length with:
+//     len(mockedVaultMountsAPI.RemountCalls())
+func (mock *VaultMountsAPIMock) RemountCalls() []struct {
+	From string
+	To   string
+} {
+	var calls []struct {
+		From string
+		To   string
+	}
+	lockVaultMountsAPIMockRemount.RLock()
+	calls = mock.calls.Remount
+	lockVaultMountsAPIMockRemount.RUnlock()
+	return calls
+}
+
+// Unmount calls UnmountFunc.
+func (mock *VaultMountsAPIMock) Unmount(path string) error {
+	if mock.UnmountFunc == nil {
+		panic("VaultMountsAPIMock.UnmountFunc: method is nil but VaultMountsAPI.Unmount was just called")
+	}
+	callInfo := struct {
+		Path string
+	}{
+		Path: path,
+	}
+	lockVaultMountsAPIMockUnmount.Lock()
+	mock.calls.Unmount = append(mock.calls.Unmount, callInfo)
+	lockVaultMountsAPIMockUnmount.Unlock()
+	return mock.UnmountFunc(path)
+}
+
+// UnmountCalls gets all the calls that were made to Unmount.
+// Check the length with:
+//     len(mockedVaultMountsAPI.UnmountCalls())
+func (mock *VaultMountsAPIMock) UnmountCalls() []struct {
+	Path string
+} {
+	var calls []struct {
+		Path string
+	}
+	lockVaultMountsAPIMockUnmount.RLock()
+	calls = mock.calls.Unmount
+	lockVaultMountsAPIMockUnmount.RUnlock()
+	return calls
+}
@@ -1,8 +1,19 @@
 package main
 
 import (
-	vault "github.com/hashicorp/vault/api"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
 )
 
 const expectedPolicy = `
@@ -27,7 +38,10 @@ path "minio2/keys/profile-test" {
 
 func TestGeneratePolicy(t *testing.T) {
 
-	policy, err := generatePolicy("profile-test", []string{"minio1", "minio2"})
+	policy, err := generatePolicy(POLICY_TEMPLATE, PolicyTemplateData{
+		ProfileName:    "profile-test",
+		MinioInstances: []string{"minio1", "minio2"},
+	})
 
 	if err != nil {
 		t.Fatal(err)
@@ -75,7 +89,7 @@ func TestDoPolicy_updatePolicy(t *testing.T) {
 		},
 		MinioInstances: []string{"minio1", "minio2"},
 	}
-	policyName, _ := vc.doPolicy("profile-test", "kv_profile-test")
+	policyName, _ := vc.doPolicy("profile-test", SecretStoreProfile{Name: "test"})
 
 	if policyName != "profile-test" {
 		t.Logf("Expected profile-test as policy name, got %s", policyName)
@@ -83,6 +97,547 @@ func TestDoPolicy_updatePolicy(t *testing.T) {
 	}
 }
 
+func TestDoPolicy_policyTier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	readOnly := `path "kv_{{ .ProfileName }}/*" { capabilities = ["read"] } # {{ .Owner }} {{ .Namespace }}`
+	if err := ioutil.WriteFile(path.Join(dir, "read-only.hcl"), []byte(readOnly), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var written string
+	var vc = VaultConfigurerStruct{
+		Logical: &VaultLogicalAPIMock{
+			ReadFunc: func(path string) (*vault.Secret, error) {
+				return nil, nil
+			},
+			WriteFunc: func(path string, data map[string]interface{}) (*vault.Secret, error) {
+				written = data["policy"].(string)
+				return &vault.Secret{}, nil
+			},
+		},
+		MinioInstances:     []string{"minio1", "minio2"},
+		PolicyTemplatesDir: dir,
+		DefaultPolicyTier:  STANDARD_POLICY_TIER,
+	}
+
+	if _, err := vc.doPolicy("profile-test", SecretStoreProfile{Name: "test", Owner: "owner", PolicyTier: "read-only"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := `path "kv_profile-test/*" { capabilities = ["read"] } # owner test`; written != expected {
+		t.Errorf("Expected %q as policy, got %q", expected, written)
+	}
+
+	// The built-in template is used when the standard tier has no file
+	if _, err := vc.doPolicy("profile-test", SecretStoreProfile{Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if written != expectedPolicy {
+		t.Errorf("Expected the built-in policy, got %q", written)
+	}
+
+	if _, err := vc.doPolicy("profile-test", SecretStoreProfile{Name: "test", PolicyTier: "unknown"}); err == nil {
+		t.Error("Expected an error for an unknown policy tier")
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	if err := validatePolicy("profile-test", expectedPolicy); err != nil {
+		t.Errorf("Expected the built-in policy to be valid, got %v", err)
+	}
+
+	invalidPolicies := map[string]string{
+		"malformed":         `path "kv_profile-test/*" { capabilities = ["read"]`,
+		"wildcard":          `path "kv_profile-other/*" { capabilities = ["read"] }`,
+		"glob":              `path "sys/+/acl" { capabilities = ["read"] }`,
+		"unexpected stanza": `role "admin" { capabilities = ["read"] }`,
+	}
+
+	for name, policy := range invalidPolicies {
+		if _, ok := validatePolicy("profile-test", policy).(*InvalidPolicyError); !ok {
+			t.Errorf("Expected the %s policy to be rejected", name)
+		}
+	}
+}
+
+// Tests that an invalid policy is never written to Vault
+func TestDoPolicy_invalidPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(path.Join(dir, "standard.hcl"), []byte(`path "*" { capabilities = ["sudo"] }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var vc = VaultConfigurerStruct{
+		Logical:            &VaultLogicalAPIMock{},
+		PolicyTemplatesDir: dir,
+	}
+
+	if _, err := vc.doPolicy("profile-test", SecretStoreProfile{Name: "test"}); err == nil {
+		t.Fatal("Expected the policy to be rejected")
+	}
+
+	if len(vc.Logical.(*VaultLogicalAPIMock).WriteCalls()) != 0 {
+		t.Error("Invalid policy should not be written")
+	}
+}
+
+// Tests that the ServiceAccounts of other namespaces get a role per namespace
+func TestDoServiceAccountRoles(t *testing.T) {
+	var vc = VaultConfigurerStruct{
+		Logical: &VaultLogicalAPIMock{
+			ReadFunc: func(path string) (*vault.Secret, error) {
+				return nil, nil
+			},
+			WriteFunc: func(path string, data map[string]interface{}) (*vault.Secret, error) {
+				return &vault.Secret{}, nil
+			},
+		},
+		KubernetesAuthPath: "auth/kubernetes",
+	}
+
+	roleNames, err := vc.doServiceAccountRoles("test", "profile-test", VIEWER, "profile-test.viewer", []string{
+		"test/default-editor",
+		"other/pipeline",
+		"other/runner",
+	}, KubernetesRoleConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(roleNames) != 1 || roleNames[0] != "profile-test.viewer.other" {
+		t.Fatalf("Expected the profile-test.viewer.other role, got %v", roleNames)
+	}
+
+	writes := vc.Logical.(*VaultLogicalAPIMock).WriteCalls()
+	if len(writes) != 1 || writes[0].Path != "auth/kubernetes/role/profile-test.viewer.other" {
+		t.Fatalf("Expected the role to be written, got %v", writes)
+	}
+
+	if !StringArrayEquals(writes[0].Data["bound_service_account_names"].([]string), []string{"pipeline", "runner"}) {
+		t.Errorf("Unexpected ServiceAccounts %v", writes[0].Data["bound_service_account_names"])
+	}
+
+	if !StringArrayEquals(writes[0].Data["token_policies"].([]string), []string{DEFAULT, "profile-test.viewer"}) {
+		t.Errorf("Unexpected policies %v", writes[0].Data["token_policies"])
+	}
+}
+
+// Tests that orphaned entities are marked, then removed
+// after the grace period, and unmarked if they come back
+func TestSweepEntities(t *testing.T) {
+	now := time.Now()
+	entities := map[string]map[string]interface{}{
+		"owner":    {"created_by": CREATED_BY},
+		"returned": {"created_by": CREATED_BY, ORPHANED_SINCE: now.Add(-time.Hour).UTC().Format(time.RFC3339)},
+		"departed": {"created_by": CREATED_BY, "team": "daaas"},
+		"recent":   {"created_by": CREATED_BY, ORPHANED_SINCE: now.Add(-time.Hour).UTC().Format(time.RFC3339)},
+		"expired":  {"created_by": CREATED_BY, ORPHANED_SINCE: now.Add(-48 * time.Hour).UTC().Format(time.RFC3339)},
+		"foreign":  {},
+	}
+
+	var vc = VaultConfigurerStruct{
+		Logical: &VaultLogicalAPIMock{
+			ListFunc: func(path string) (*vault.Secret, error) {
+				keys := []interface{}{}
+				for name := range entities {
+					keys = append(keys, name)
+				}
+				return &vault.Secret{Data: map[string]interface{}{"keys": keys}}, nil
+			},
+			ReadFunc: func(path string) (*vault.Secret, error) {
+				name := strings.TrimPrefix(path, "/identity/entity/name/")
+				return &vault.Secret{Data: map[string]interface{}{"metadata": entities[name]}}, nil
+			},
+			WriteFunc: func(path string, data map[string]interface{}) (*vault.Secret, error) {
+				return &vault.Secret{}, nil
+			},
+			DeleteFunc: func(path string) (*vault.Secret, error) {
+				return nil, nil
+			},
+		},
+	}
+
+	err := vc.SweepEntities(map[string][]string{
+		"test": {"owner", "returned"},
+	}, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logical := vc.Logical.(*VaultLogicalAPIMock)
+	written := map[string]map[string]string{}
+	for _, call := range logical.WriteCalls() {
+		written[call.Path] = call.Data["metadata"].(map[string]string)
+	}
+
+	if len(written) != 2 {
+		t.Errorf("Expected 2 entities to be written, got %v", written)
+	}
+
+	if metadata, ok := written["/identity/entity/name/returned"]; !ok || metadata[ORPHANED_SINCE] != "" {
+		t.Errorf("Expected the returned entity to be unmarked, got %v", metadata)
+	}
+
+	if metadata, ok := written["/identity/entity/name/departed"]; !ok || metadata[ORPHANED_SINCE] == "" {
+		t.Errorf("Expected the departed entity to be marked, got %v", metadata)
+	}
+
+	if metadata := written["/identity/entity/name/departed"]; metadata["team"] != "daaas" || metadata["created_by"] != CREATED_BY {
+		t.Errorf("Expected the other metadata of the departed entity to be kept, got %v", metadata)
+	}
+
+	deletes := logical.DeleteCalls()
+	if len(deletes) != 1 || deletes[0].Path != "/identity/entity/name/expired" {
+		t.Errorf("Expected only the expired entity to be deleted, got %v", deletes)
+	}
+}
+
+// Tests that the role of a profile is brought back to the
+// configuration merged from the defaults and the profile
+func TestDoKubernetesBackendRole_config(t *testing.T) {
+	var vc = VaultConfigurerStruct{
+		Logical: &VaultLogicalAPIMock{
+			ReadFunc: func(path string) (*vault.Secret, error) {
+				return &vault.Secret{
+					Data: map[string]interface{}{
+						"bound_service_account_names":      []interface{}{"*"},
+						"bound_service_account_namespaces": []interface{}{"test"},
+						"token_policies":                   []interface{}{DEFAULT, "profile-test"},
+						"token_ttl":                        json.Number("3600"),
+						"token_max_ttl":                    json.Number("0"),
+					},
+				}, nil
+			},
+			WriteFunc: func(path string, data map[string]interface{}) (*vault.Secret, error) {
+				return &vault.Secret{}, nil
+			},
+		},
+		KubernetesAuthPath: "auth/kubernetes",
+		KubernetesRole: KubernetesRoleConfig{
+			TokenTTL: time.Hour,
+			Audience: "vault",
+			Policies: []string{"shared"},
+		},
+	}
+
+	role := vc.KubernetesRole.merge(KubernetesRoleConfig{
+		ServiceAccounts: []string{"default-editor"},
+		TokenMaxTTL:     2 * time.Hour,
+		Policies:        []string{"protected"},
+	})
+
+	if err := vc.doKubernetesBackendRole("test", "profile-test", "profile-test", role); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := vc.Logical.(*VaultLogicalAPIMock).WriteCalls()
+	if len(writes) != 1 {
+		t.Fatalf("Expected the role to be updated, got %d writes", len(writes))
+	}
+
+	expected := map[string]interface{}{
+		"bound_service_account_names": []string{"default-editor"},
+		"token_policies":              []string{DEFAULT, "profile-test", "shared", "protected"},
+		"token_max_ttl":               int64(7200),
+		"audience":                    "vault",
+	}
+
+	if !reflect.DeepEqual(writes[0].Data, expected) {
+		t.Errorf("Expected %v, got %v", expected, writes[0].Data)
+	}
+}
+
+func newDeconfigVaultConfigurer(mounts map[string]*vault.MountOutput) *VaultConfigurerStruct {
+	return &VaultConfigurerStruct{
+		Logical: &VaultLogicalAPIMock{
+			DeleteFunc: func(path string) (*vault.Secret, error) {
+				return nil, nil
+			},
+			ListFunc: func(path string) (*vault.Secret, error) {
+				return &vault.Secret{
+					Data: map[string]interface{}{
+						"keys": []interface{}{"profile-test", "profile-test.viewer.other", "profile-test-other"},
+					},
+				}, nil
+			},
+		},
+		Mounts: &VaultMountsAPIMock{
+			ListMountsFunc: func() (map[string]*vault.MountOutput, error) {
+				return mounts, nil
+			},
+			UnmountFunc: func(path string) error {
+				return nil
+			},
+			RemountFunc: func(from, to string) error {
+				return nil
+			},
+		},
+		KubernetesAuthPath: "auth/kubernetes",
+		MinioInstances:     []string{"minio1", "minio2"},
+	}
+}
+
+// Tests that every artifact is deleted and the KV mount
+// is archived when the profile isn't purged
+func TestDeconfigVaultForProfile_archive(t *testing.T) {
+	vc := newDeconfigVaultConfigurer(map[string]*vault.MountOutput{
+		"kv_profile-test/": {},
+	})
+
+	if err := vc.DeconfigVaultForProfile("test", false); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedDeletes := []string{
+		"/identity/group/name/profile-test",
+		"/identity/group/name/profile-test.viewer",
+		"minio1/roles/profile-test",
+		"minio2/roles/profile-test",
+		"auth/kubernetes/role/profile-test",
+		"auth/kubernetes/role/profile-test.viewer.other",
+		"/sys/policies/acl/profile-test",
+		"/sys/policies/acl/profile-test.viewer",
+	}
+
+	deletes := vc.Logical.(*VaultLogicalAPIMock).DeleteCalls()
+	if len(deletes) != len(expectedDeletes) {
+		t.Fatalf("Expected %d deletes, got %d", len(expectedDeletes), len(deletes))
+	}
+
+	for i, call := range deletes {
+		if call.Path != expectedDeletes[i] {
+			t.Errorf("Expected delete of %s, got %s", expectedDeletes[i], call.Path)
+		}
+	}
+
+	mounts := vc.Mounts.(*VaultMountsAPIMock)
+	if len(mounts.UnmountCalls()) != 0 {
+		t.Error("Mount should not be removed when archiving")
+	}
+
+	remounts := mounts.RemountCalls()
+	if len(remounts) != 1 {
+		t.Fatalf("Expected 1 remount, got %d", len(remounts))
+	}
+
+	if remounts[0].From != "kv_profile-test" || !strings.HasPrefix(remounts[0].To, "archive/kv_profile-test-") {
+		t.Errorf("Unexpected remount from %s to %s", remounts[0].From, remounts[0].To)
+	}
+}
+
+// Tests that the KV mount is removed when the profile is purged
+func TestDeconfigVaultForProfile_purge(t *testing.T) {
+	vc := newDeconfigVaultConfigurer(map[string]*vault.MountOutput{
+		"kv_profile-test/": {},
+	})
+
+	if err := vc.DeconfigVaultForProfile("test", true); err != nil {
+		t.Fatal(err)
+	}
+
+	mounts := vc.Mounts.(*VaultMountsAPIMock)
+	if len(mounts.RemountCalls()) != 0 {
+		t.Error("Mount should not be archived when purging")
+	}
+
+	unmounts := mounts.UnmountCalls()
+	if len(unmounts) != 1 || unmounts[0].Path != "kv_profile-test" {
+		t.Errorf("Expected kv_profile-test to be removed, got %v", unmounts)
+	}
+}
+
+// Tests that a profile whose mount is already gone
+// can still be finalized
+func TestDeconfigVaultForProfile_noMount(t *testing.T) {
+	vc := newDeconfigVaultConfigurer(map[string]*vault.MountOutput{})
+
+	if err := vc.DeconfigVaultForProfile("test", true); err != nil {
+		t.Fatal(err)
+	}
+
+	mounts := vc.Mounts.(*VaultMountsAPIMock)
+	if len(mounts.UnmountCalls()) != 0 || len(mounts.RemountCalls()) != 0 {
+		t.Error("Missing mount should not be modified")
+	}
+}
+
+type vaultRequest struct {
+	Request   string
+	Namespace string
+}
+
+// Starts a fake Vault which records the namespace
+// header of every request it receives
+func newNamespaceRecordingVault(t *testing.T) (*httptest.Server, func() []vaultRequest) {
+	var lock sync.Mutex
+	requests := []vaultRequest{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests = append(requests, vaultRequest{
+			Request:   r.Method + " " + r.URL.Path,
+			Namespace: r.Header.Get("X-Vault-Namespace"),
+		})
+		lock.Unlock()
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/sys/mounts":
+			w.Write([]byte(`{"data": {"kv_profile-test/": {"type": "kv"}}}`))
+		case strings.HasPrefix(r.URL.Path, "/v1/identity/entity/name/") && r.Method != http.MethodDelete:
+			w.Write([]byte(`{"data": {"id": "entity-id", "aliases": []}}`))
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	return server, func() []vaultRequest {
+		lock.Lock()
+		defer lock.Unlock()
+		return requests
+	}
+}
+
+func newNamespacedVaultConfigurer(t *testing.T, address string) *VaultConfigurerStruct {
+	client, err := vault.NewClient(&vault.Config{Address: address})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("test-token")
+
+	return NewVaultConfigurer(client, "auth/kubernetes", "oidc-accessor", []string{"minio1"}, "env/{{ .ProfileName }}")
+}
+
+// profileRequestNamespace returns the namespace expected for a request
+// configuring the profile "test": the MinIO roles stay in the namespace
+// of the controller, with the configuration of the MinIO plugin.
+func profileRequestNamespace(request vaultRequest) string {
+	if strings.Contains(request.Request, " /v1/minio1/") {
+		return ""
+	}
+
+	return "env/test"
+}
+
+// Tests that every request configuring a profile is
+// sent to the Vault namespace of the profile
+func TestConfigVaultForProfile_namespace(t *testing.T) {
+	server, namespaces := newNamespaceRecordingVault(t)
+	defer server.Close()
+
+	vc := newNamespacedVaultConfigurer(t, server.URL)
+	if err := vc.ConfigVaultForProfile(SecretStoreProfile{Name: "test", Owner: "owner", Editors: SecretStoreSubjects{Users: []string{"user"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := namespaces()
+	if len(requests) == 0 {
+		t.Fatal("Expected requests to be sent to Vault")
+	}
+
+	for _, request := range requests {
+		if namespace := profileRequestNamespace(request); request.Namespace != namespace {
+			t.Errorf("Expected %s to be sent to namespace %q, got %q", request.Request, namespace, request.Namespace)
+		}
+	}
+}
+
+// Tests that every request removing a profile is
+// sent to the Vault namespace of the profile
+func TestDeconfigVaultForProfile_namespace(t *testing.T) {
+	server, namespaces := newNamespaceRecordingVault(t)
+	defer server.Close()
+
+	vc := newNamespacedVaultConfigurer(t, server.URL)
+	if err := vc.DeconfigVaultForProfile("test", false); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := namespaces()
+	archived := false
+	for _, request := range requests {
+		archived = archived || request.Request == "POST /v1/sys/remount"
+	}
+
+	if !archived {
+		t.Error("Expected the mount to be archived")
+	}
+
+	for _, request := range requests {
+		if namespace := profileRequestNamespace(request); request.Namespace != namespace {
+			t.Errorf("Expected %s to be sent to namespace %q, got %q", request.Request, namespace, request.Namespace)
+		}
+	}
+}
+
+// Tests that the MinIO roles of a profile are written to the
+// mount the configuration of the MinIO instance is read from
+func TestConfigVaultForProfile_minioNamespace(t *testing.T) {
+	server, namespaces := newNamespaceRecordingVault(t)
+	defer server.Close()
+
+	vc := newNamespacedVaultConfigurer(t, server.URL)
+	if err := vc.ConfigVaultForProfile(SecretStoreProfile{Name: "test", Owner: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake Vault has no configuration to return
+	vc.GetMinIOConfiguration("minio1")
+
+	minioNamespaces := map[string][]string{}
+	for _, request := range namespaces() {
+		if strings.Contains(request.Request, " /v1/minio1/") {
+			minioNamespaces[request.Namespace] = append(minioNamespaces[request.Namespace], request.Request)
+		}
+	}
+
+	expected := map[string][]string{
+		"": {
+			"GET /v1/minio1/roles/profile-test",
+			"PUT /v1/minio1/roles/profile-test",
+			"GET /v1/minio1/config",
+		},
+	}
+	if !reflect.DeepEqual(minioNamespaces, expected) {
+		t.Errorf("Expected the MinIO requests %v, got %v", expected, minioNamespaces)
+	}
+}
+
+// Tests that the profile namespace doesn't leak
+// into the requests which aren't for a profile
+func TestForProfile_keepsRootClient(t *testing.T) {
+	server, namespaces := newNamespaceRecordingVault(t)
+	defer server.Close()
+
+	vc := newNamespacedVaultConfigurer(t, server.URL)
+	if _, err := vc.forProfile("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vc.Mounts.ListMounts(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, request := range namespaces() {
+		if request.Namespace != "" {
+			t.Errorf("Expected %s to be sent without namespace, got %q", request.Request, request.Namespace)
+		}
+	}
+}
+
 //func TestDoKVMount_NoMount(t *testing.T) {
 //	var vc = VaultConfigurerStruct{
 //		Logical: nil,
@@ -97,3 +652,49 @@ func TestDoPolicy_updatePolicy(t *testing.T) {
 //		MinioInstances:     []string{"minio1", "minio2"},
 //	}
 //}
+
+// Tests that the roles of the MinIO plugin created with the
+// former user name prefix are brought to the new one
+func TestDoMinioRole_userNamePrefix(t *testing.T) {
+	roles := map[string]map[string]interface{}{
+		"minio1/roles/profile-new": nil,
+		"minio1/roles/profile-old": {"policy": "readonly", "user_name_prefix": "profile-old-"},
+		"minio1/roles/profile-ok":  {"policy": "readonly", "user_name_prefix": "profile-ok_"},
+	}
+
+	var vc = VaultConfigurerStruct{
+		Logical: &VaultLogicalAPIMock{
+			ReadFunc: func(path string) (*vault.Secret, error) {
+				if roles[path] == nil {
+					return nil, nil
+				}
+				return &vault.Secret{Data: roles[path]}, nil
+			},
+			WriteFunc: func(path string, data map[string]interface{}) (*vault.Secret, error) {
+				return &vault.Secret{}, nil
+			},
+		},
+	}
+
+	for _, name := range []string{"profile-new", "profile-old", "profile-ok"} {
+		if err := vc.doMinioRole("minio1", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writes := vc.Logical.(*VaultLogicalAPIMock).WriteCalls()
+	if len(writes) != 3 {
+		t.Fatalf("Expected 2 roles to be written and the credentials of 1 to be revoked, got %v", writes)
+	}
+
+	for i, name := range []string{"profile-new", "profile-old"} {
+		if writes[i].Path != "minio1/roles/"+name || writes[i].Data["user_name_prefix"] != name+"_" {
+			t.Errorf("Expected the %s role to be written with the %s_ prefix, got %v", name, name, writes[i])
+		}
+	}
+
+	// The credentials issued with the former prefix are rotated
+	if writes[2].Path != "sys/leases/revoke-prefix/minio1/keys/profile-old" {
+		t.Errorf("Expected the credentials of the profile-old role to be revoked, got %v", writes[2])
+	}
+}